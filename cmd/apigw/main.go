@@ -1,72 +1,155 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/arjunksofficial/tyk-task/internal/audit"
 	"github.com/arjunksofficial/tyk-task/internal/config"
-	"github.com/arjunksofficial/tyk-task/internal/middlewares/auth"
-	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
-	"github.com/arjunksofficial/tyk-task/internal/middlewares/ratelimit"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
 	"github.com/arjunksofficial/tyk-task/internal/ready"
-	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/arjunksofficial/tyk-task/internal/rediscli"
+	"github.com/arjunksofficial/tyk-task/internal/router"
+	"github.com/arjunksofficial/tyk-task/internal/tracing"
 )
 
+// flushTimeout bounds sending the spans still buffered on shutdown
+const flushTimeout = 5 * time.Second
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+	applyConfigFlags := configFlags(flag.CommandLine)
+	flag.Parse()
+	applyConfigFlags()
+
 	cfg, err := config.ReadConfig()
 	if err != nil {
 		log.Fatalf("Error reading config: %v", err)
 	}
 	log.Printf("Config loaded: %+v", cfg)
-	router := mux.NewRouter()
+	metrics.Init()
+	flushTraces, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+	if err := audit.Init(cfg.Audit); err != nil {
+		log.Fatalf("Error setting up audit log: %v", err)
+	}
 
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET").Name("HealthCheck")
-	router.HandleFunc("/ready", ready.ReadyHandler).Methods("GET").Name("ReadyCheck")
-	router.Handle("/metrics", promhttp.Handler())
-	router.Use(logging.LoggingMiddleware)
+	handler, err := router.NewReloader(cfg, build)
+	if err != nil {
+		log.Fatalf("Error building routes: %v", err)
+	}
 
-	for _, route := range cfg.GetRoutes() {
-		target, err := url.Parse(route.Host)
-		if err != nil {
-			log.Fatalf("Error parsing URL %s: %v", route.Host, err)
+	// Reload routes when master.yaml changes or on SIGHUP
+	stopWatch := config.Watch(func() { reload(handler) })
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		for range hup {
+			reload(handler)
 		}
-		// implement forward proxy for each route
-		proxy := httputil.NewSingleHostReverseProxy(target)
+	}()
+	stopReloads := func() {
+		stopWatch()
+		signal.Stop(hup)
+		close(hup)
+		<-reloaded
+	}
 
-		// use regext match for path/* to rouute to the proxy
+	srv, err := newServer(cfg, handler)
+	if err != nil {
+		log.Fatalf("Error creating server: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
 
-		router.PathPrefix(route.Path).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Modify the request URL to match the target host
-			r.URL.Host = target.Host
-			r.URL.Scheme = target.Scheme
-			r.Host = target.Host
-			r.Header.Set("X-Forwarded-Host", r.Host)
-			r.Header.Set("X-Forwarded-For", r.RemoteAddr)
-			r.Header.Set("X-Forwarded-Proto", target.Scheme)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-served:
+		log.Fatal(err)
+	case sig := <-stop:
+		shutdown(cfg, srv, handler, stopReloads, flushTraces, sig, stop)
+	}
+}
 
-			// Log the request for debugging
-			log.Printf("Proxying request: %s %s to %s", r.Method, r.URL.Path, target.String())
-			// set middlewares for logging, auth, and rate limiting
-			// Note: Middlewares are already applied at the router level To remove from the global middlewares
-			// and apply them specifically for this route, you can do so here
-			router.Use(auth.NewAuthMiddleware().AuthMiddleware)
-			router.Use(ratelimit.NewRateLimitMiddleware().RateLimitHandler)
+// shutdown drains the gateway: it stops reloading the config and fails the
+// readiness check for the drain period while still serving, then waits for
+// requests in flight and closes the upstream and Redis connections and the
+// audit log and flushes the traces. A second signal skips the drain period.
+func shutdown(cfg *config.Config, srv *server, handler *router.Reloader, stopReloads func(), flushTraces func(context.Context) error, sig os.Signal, stop <-chan os.Signal) {
+	drain := orDefault(cfg.App.DrainPeriod, defaultDrainPeriod)
+	log.Printf("Received %s, draining for %s", sig, drain)
+	// A reload must not rebuild routes on the connections closed below
+	stopReloads()
+	ready.SetDraining()
+	srv.Drain()
+	select {
+	case <-time.After(drain):
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down now", sig)
+	}
 
-			// Serve the request using the reverse proxy
-			proxy.ServeHTTP(w, r)
-		})
-		log.Printf("Route registered: %s -> %s", route.Path, route.Host)
+	ctx, cancel := context.WithTimeout(context.Background(), orDefault(cfg.App.ShutdownTimeout, defaultShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown did not complete cleanly: %v", err)
+	}
+	handler.Close()
+	if err := rediscli.CloseRedisClient(); err != nil {
+		log.Printf("Closing Redis client failed: %v", err)
 	}
+	if err := audit.Close(); err != nil {
+		log.Printf("Closing audit log failed: %v", err)
+	}
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer flushCancel()
+	if err := flushTraces(flushCtx); err != nil {
+		log.Printf("Flushing traces failed: %v", err)
+	}
+	log.Println("Shutdown complete")
+}
 
-	log.Println("Proxy listening on :" + cfg.GetPort())
-	log.Fatal(http.ListenAndServe(":"+cfg.GetPort(), router))
+func build(cfg *config.Config) (http.Handler, error) {
+	return router.New(cfg, middlewares.DefaultRegistry(cfg))
 }
 
-// Health check handler
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// reload swaps in the routes of the config file, keeping the current ones
+// when the file is invalid. The listener and Redis connection are not
+// affected; changing those still requires a restart.
+func reload(handler *router.Reloader) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Config reload failed, keeping current routes: %v", err)
+		return
+	}
+	if err := handler.Reload(cfg); err != nil {
+		log.Printf("Config reload failed, keeping current routes: %v", err)
+		return
+	}
+	config.SetConfig(cfg)
+	log.Printf("Config reloaded: %d routes", len(cfg.GetRoutes()))
+}
+
+// configFlags registers the flags selecting the config file. The returned
+// func applies them once fs is parsed.
+func configFlags(fs *flag.FlagSet) func() {
+	env := fs.String("env", "", "read config/<env>/master.yaml (default $"+config.EnvVar+" or "+config.DefaultEnv+")")
+	path := fs.String("config", "", "path of the config file, overriding -env")
+	return func() {
+		config.SetEnv(*env)
+		config.SetPath(*path)
+	}
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/tlsconfig"
+)
+
+// Listener timeouts used when app config leaves them at zero. The write
+// timeout leaves room for slow upstreams behind the proxy.
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 60 * time.Second
+	defaultWriteTimeout      = 120 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultDrainPeriod       = 5 * time.Second
+	defaultShutdownTimeout   = 20 * time.Second
+)
+
+// server is the gateway's listener, serving HTTPS when TLS is configured,
+// along with the optional HTTP redirect listener
+type server struct {
+	*http.Server
+	port     string
+	redirect *http.Server
+	certs    *tlsconfig.CertReloader
+}
+
+// newServer creates the gateway's server
+func newServer(cfg *config.Config, handler http.Handler) (*server, error) {
+	srv := &server{
+		Server: &http.Server{
+			Addr:              ":" + cfg.GetPort(),
+			Handler:           handler,
+			ReadHeaderTimeout: orDefault(cfg.App.ReadHeaderTimeout, defaultReadHeaderTimeout),
+			ReadTimeout:       orDefault(cfg.App.ReadTimeout, defaultReadTimeout),
+			WriteTimeout:      orDefault(cfg.App.WriteTimeout, defaultWriteTimeout),
+			IdleTimeout:       orDefault(cfg.App.IdleTimeout, defaultIdleTimeout),
+		},
+		port: cfg.GetPort(),
+	}
+	if !cfg.TLS.Enabled() {
+		return srv, nil
+	}
+
+	tlsCfg, certs, err := tlsconfig.NewServer(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = tlsCfg
+	srv.certs = certs
+	if port := cfg.TLS.RedirectPort; port != "" {
+		srv.redirect = &http.Server{
+			Addr:              ":" + port,
+			Handler:           tlsconfig.RedirectHandler(cfg.GetPort()),
+			ReadHeaderTimeout: srv.ReadHeaderTimeout,
+		}
+	}
+	return srv, nil
+}
+
+// Serve serves requests until the server is shut down, returning
+// http.ErrServerClosed then
+func (s *server) Serve() error {
+	if s.certs == nil {
+		log.Println("Proxy listening on :" + s.port)
+		return s.ListenAndServe()
+	}
+	if s.redirect != nil {
+		go func() {
+			log.Println("Redirecting HTTP on " + s.redirect.Addr + " to HTTPS")
+			if err := s.redirect.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+	log.Println("Proxy listening with TLS on :" + s.port)
+	return s.ListenAndServeTLS("", "")
+}
+
+// Drain stops keeping connections alive, so clients reconnect, ideally to
+// another instance, once their current request is answered
+func (s *server) Drain() {
+	s.SetKeepAlivesEnabled(false)
+}
+
+// Shutdown stops the listeners and waits for requests in flight until ctx
+// is done, closing the connections left then
+func (s *server) Shutdown(ctx context.Context) error {
+	var errs []error
+	if s.redirect != nil {
+		errs = append(errs, s.redirect.Shutdown(ctx))
+	}
+	if err := s.Server.Shutdown(ctx); err != nil {
+		errs = append(errs, err, s.Close())
+	}
+	if s.certs != nil {
+		errs = append(errs, s.certs.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freePort returns a port nothing listens on
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+	return port
+}
+
+func TestShutdown_DrainsRequestsInFlight(t *testing.T) {
+	received := make(chan struct{})
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{Path: "/api/v1/slow/", Host: slow.URL, Middlewares: []string{}},
+		},
+	}
+	cfg.App.Port = freePort(t)
+	cfg.App.DrainPeriod = 200 * time.Millisecond
+	cfg.App.ShutdownTimeout = 5 * time.Second
+	cfg.Redis.Host = "localhost"
+	cfg.Redis.Port = "6379"
+	cfg.Security.APIKeyPepper = "pepper"
+	handler, err := router.NewReloader(cfg, func(cfg *config.Config) (http.Handler, error) {
+		return router.New(cfg, middlewares.Registry{})
+	})
+	require.NoError(t, err)
+	srv, err := newServer(cfg, handler)
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
+
+	base := "http://127.0.0.1:" + cfg.App.Port
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", "127.0.0.1:"+cfg.App.Port)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(base + "/api/v1/slow/1")
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+	<-received
+
+	reloadsStopped := false
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		flush := func(context.Context) error { return nil }
+		shutdown(cfg, srv, handler, func() { reloadsStopped = true }, flush, syscall.SIGTERM, make(chan os.Signal))
+	}()
+
+	// The listener keeps serving during the drain period, failing /ready
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(base + "/ready")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	close(release)
+	res := <-inFlight
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "slow", res.body)
+
+	select {
+	case <-shutdownDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not complete")
+	}
+	assert.True(t, reloadsStopped)
+	assert.True(t, errors.Is(<-served, http.ErrServerClosed))
+	_, err = http.Get(base + "/ready")
+	assert.Error(t, err)
+}
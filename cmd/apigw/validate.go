@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
+	"github.com/arjunksofficial/tyk-task/internal/upstream"
+	"github.com/redis/go-redis/v9"
+)
+
+// validate checks a config file without starting the gateway and prints
+// every problem found. It returns the process exit code.
+func validate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	applyConfigFlags := configFlags(fs)
+	fs.Parse(args)
+	applyConfigFlags()
+
+	path := config.File()
+	cfg, err := config.LoadFile(path)
+	if err == nil {
+		err = validateRoutes(cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid:\n", path)
+		for _, e := range unwrapJoined(err) {
+			fmt.Fprintf(os.Stderr, "  %v\n", e)
+		}
+		return 1
+	}
+	fmt.Printf("%s is valid\n", path)
+	return 0
+}
+
+// validateRoutes builds every route's middleware chain, upstream pool and
+// transport the way the gateway would, without connecting to anything
+func validateRoutes(cfg *config.Config) error {
+	// Building a chain creates the Redis clients of its middlewares but
+	// sends no commands, so this client is never dialed
+	offline := redis.NewClient(&redis.Options{Addr: cfg.Redis.Host + ":" + cfg.Redis.Port})
+	defer offline.Close()
+	registry := middlewares.NewRegistry(cfg, func() *redis.Client { return offline })
+
+	errs := []error{registry.Validate(cfg.GetRoutes())}
+	if _, err := logging.NewAccessLogger(cfg.AccessLog, io.Discard); err != nil {
+		errs = append(errs, err)
+	}
+	for _, route := range cfg.GetRoutes() {
+		// The pool builds the route's transport, loading its TLS files
+		if _, err := upstream.NewPool(route); err != nil {
+			errs = append(errs, err)
+		}
+		// Unknown middlewares are reported by Validate above
+		if registry.Validate([]config.Route{route}) != nil {
+			continue
+		}
+		if _, err := registry.Build(route); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// unwrapJoined flattens errors combined with errors.Join
+func unwrapJoined(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, unwrapJoined(e)...)
+	}
+	return errs
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRoutes(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		routes   string
+		wantErrs []string
+	}{
+		{
+			desc: "Test valid routes do not connect to Redis",
+			routes: `
+  - path: /api/v1/orders/
+    host: http://localhost:8000
+  - path: /api/v1/public/
+    host: http://localhost:8001
+    auth:
+      type: keyless
+    middlewares: [auth]
+`,
+		},
+		{
+			desc: "Test every route is built",
+			routes: `
+  - path: /api/v1/docs/
+    host: http://localhost:8000
+    auth:
+      type: basic
+      htpasswd_file: /nonexistent/htpasswd
+      rate_limit: 10
+  - path: /api/v1/users/
+    host: http://localhost:8000
+    auth:
+      type: jwt
+  - path: /api/v1/orders/
+    host: http://localhost:8000
+    rate_limit:
+      algorithm: leaky_bucket
+  - path: /api/v1/billing/
+    host: https://localhost:8000
+    tls:
+      ca_file: /nonexistent/ca.pem
+  - path: /api/v1/reports/
+    host: http://localhost:8000
+    middlewares: [auth, cache]
+`,
+			wantErrs: []string{
+				`route /api/v1/docs/: building middleware "auth": reading htpasswd /nonexistent/htpasswd`,
+				`route /api/v1/users/: building middleware "auth": jwt auth requires jwt.hmac_secret or jwt.jwks_file`,
+				`route /api/v1/orders/: building middleware "ratelimit": unknown rate limit algorithm "leaky_bucket"`,
+				"route /api/v1/billing/: tls: reading CA bundle",
+				`routes[4].middlewares: unknown middleware "cache"`,
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "master.yaml")
+			content := "redis:\n  host: localhost\n  port: 1\nsecurity:\n  api_key_pepper: pepper\nroutes:" + tC.routes
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			cfg, err := config.LoadFile(path)
+			require.NoError(t, err)
+
+			err = validateRoutes(cfg)
+
+			if len(tC.wantErrs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Len(t, unwrapJoined(err), len(tC.wantErrs))
+			for _, want := range tC.wantErrs {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/ratelimit"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// stdout receives the output of the commands
+var stdout io.Writer = os.Stdout
+
+// runFunc executes a parsed subcommand
+type runFunc func(ctx context.Context, svc tokenservice.Service) error
+
+// command is a tokengen subcommand. setup registers its flags and returns
+// the function to run once they are parsed, so that flag errors and -h are
+// handled before connecting to Redis.
+type command struct {
+	name  string
+	usage string
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+var commands = []command{
+	{name: "create", usage: "create a token from the spec file", setup: createCmd},
+	{name: "bulk-create", usage: "create N tokens and write them to a CSV or JSON file", setup: bulkCreateCmd},
+	{name: "get", usage: "print a token", setup: getCmd},
+	{name: "list", usage: "print all tokens", setup: listCmd},
+	{name: "update", usage: "update the rate limit, routes or expiry of a token", setup: updateCmd},
+	{name: "revoke", usage: "delete a token", setup: revokeCmd},
+	{name: "migrate", usage: "rehash tokens stored under their plaintext key", setup: migrateCmd},
+}
+
+func createCmd(fs *flag.FlagSet) runFunc {
+	var sf specFlags
+	sf.register(fs, true)
+	return func(ctx context.Context, svc tokenservice.Service) error {
+		spec, err := sf.loadSpec(fs)
+		if err != nil {
+			return err
+		}
+		token := spec.newToken(uuid.New().String())
+		if err := svc.StoreToken(ctx, token); err != nil {
+			return fmt.Errorf("storing token: %w", err)
+		}
+
+		fmt.Fprintln(stdout, "✅ Token generated and stored in Redis:")
+		fmt.Fprintf(stdout, "API Key: %s\n", token.APIKey)
+		return nil
+	}
+}
+
+func bulkCreateCmd(fs *flag.FlagSet) runFunc {
+	var sf specFlags
+	sf.register(fs, true)
+	count := fs.Int("n", 1, "number of tokens to create")
+	output := fs.String("output", "tokens.csv", "file to write the keys to")
+	format := fs.String("format", "", "csv or json, defaults to the output file extension")
+	return func(ctx context.Context, svc tokenservice.Service) error {
+		if *count <= 0 {
+			return fmt.Errorf("-n must be positive, got %d", *count)
+		}
+		if *format == "" {
+			*format = strings.TrimPrefix(filepath.Ext(*output), ".")
+		}
+		if *format != "csv" && *format != "json" {
+			return fmt.Errorf("unsupported format %q, use csv or json", *format)
+		}
+		spec, err := sf.loadSpec(fs)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", *output, err)
+		}
+		defer f.Close()
+
+		tokens := make([]tokenRecord, 0, *count)
+		// Only hashes are stored, so keys that never reach the file are lost:
+		// revoke them rather than leave them live
+		fail := func(err error) error {
+			f.Close()
+			os.Remove(*output)
+			return errors.Join(err, discard(ctx, svc, tokens))
+		}
+		for i := 0; i < *count; i++ {
+			token := spec.newToken(uuid.New().String())
+			if err := svc.StoreToken(ctx, token); err != nil {
+				return fail(fmt.Errorf("storing token %d of %d: %w", i+1, *count, err))
+			}
+			tokens = append(tokens, tokenRecord{
+				APIKey:        token.APIKey,
+				RateLimit:     token.RateLimit,
+				ExpiresAt:     token.ExpiresAt,
+				AllowedRoutes: token.AllowedRoutes,
+			})
+		}
+		if err := writeRecords(f, *format, tokens); err != nil {
+			return fail(fmt.Errorf("writing %s: %w", *output, err))
+		}
+		if err := f.Close(); err != nil {
+			return fail(fmt.Errorf("writing %s: %w", *output, err))
+		}
+		fmt.Fprintf(stdout, "✅ %d tokens generated and written to %s\n", len(tokens), *output)
+		return nil
+	}
+}
+
+// discard revokes the tokens of a failed bulk-create. The keys it could not
+// revoke are reported so they can be revoked by hand.
+func discard(ctx context.Context, svc tokenservice.Service, tokens []tokenRecord) error {
+	var errs []error
+	for _, token := range tokens {
+		if err := svc.DeleteToken(ctx, token.APIKey); err != nil {
+			errs = append(errs, fmt.Errorf("revoking %s: %w", token.APIKey, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func getCmd(fs *flag.FlagSet) runFunc {
+	key := fs.String("key", "", "API key or key hash")
+	return func(ctx context.Context, svc tokenservice.Service) error {
+		if *key == "" {
+			return errors.New("-key is required")
+		}
+
+		token, err := getToken(ctx, svc, *key)
+		if err != nil {
+			return err
+		}
+		return printJSON(token)
+	}
+}
+
+func listCmd(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, svc tokenservice.Service) error {
+		tokens, err := svc.ListTokens(ctx)
+		if err != nil {
+			return fmt.Errorf("listing tokens: %w", err)
+		}
+		return printJSON(tokens)
+	}
+}
+
+func updateCmd(fs *flag.FlagSet) runFunc {
+	key := fs.String("key", "", "API key or key hash")
+	var sf specFlags
+	sf.register(fs, false)
+	return func(ctx context.Context, svc tokenservice.Service) error {
+		if *key == "" {
+			return errors.New("-key is required")
+		}
+		if fs.NFlag() == 1 {
+			return errors.New("nothing to update, set at least one of -rate-limit, -routes, -duration, -algorithm, -burst or -refill-rate")
+		}
+
+		token, err := getToken(ctx, svc, *key)
+		if err != nil {
+			return err
+		}
+		sf.applyTo(fs, &token)
+		if err := ratelimit.ValidateToken(token); err != nil {
+			return err
+		}
+		if err := svc.StoreToken(ctx, token); err != nil {
+			return fmt.Errorf("storing token: %w", err)
+		}
+		return printJSON(token)
+	}
+}
+
+func revokeCmd(fs *flag.FlagSet) runFunc {
+	key := fs.String("key", "", "API key or key hash")
+	return func(ctx context.Context, svc tokenservice.Service) error {
+		if *key == "" {
+			return errors.New("-key is required")
+		}
+
+		token, err := getToken(ctx, svc, *key)
+		if err != nil {
+			return err
+		}
+		if err := svc.DeleteTokenByHash(ctx, token.KeyHash); err != nil {
+			return fmt.Errorf("deleting token: %w", err)
+		}
+		fmt.Fprintf(stdout, "✅ Token %s revoked\n", *key)
+		return nil
+	}
+}
+
+func migrateCmd(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, svc tokenservice.Service) error {
+		migrated, err := svc.MigratePlaintextTokens(ctx)
+		if err != nil {
+			return fmt.Errorf("migrated %d tokens before failing: %w", migrated, err)
+		}
+		fmt.Fprintf(stdout, "✅ %d plaintext tokens rehashed\n", migrated)
+		return nil
+	}
+}
+
+// getToken fetches the token by its API key or by the key hash printed by
+// list
+func getToken(ctx context.Context, svc tokenservice.Service, key string) (models.TokenData, error) {
+	token, err := svc.GetToken(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		token, err = svc.GetTokenByHash(ctx, key)
+	}
+	if errors.Is(err, redis.Nil) {
+		return token, fmt.Errorf("token %s not found", key)
+	}
+	if err != nil {
+		return token, fmt.Errorf("getting token: %w", err)
+	}
+	return token, nil
+}
+
+// tokenRecord is a generated key as written by bulk-create
+type tokenRecord struct {
+	APIKey        string   `json:"api_key"`
+	RateLimit     int      `json:"rate_limit"`
+	ExpiresAt     string   `json:"expires_at"`
+	AllowedRoutes []string `json:"allowed_routes"`
+}
+
+func writeRecords(w io.Writer, format string, records []tokenRecord) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"api_key", "rate_limit", "expires_at", "allowed_routes"})
+	for _, r := range records {
+		cw.Write([]string{r.APIKey, strconv.Itoa(r.RateLimit), r.ExpiresAt, strings.Join(r.AllowedRoutes, ";")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
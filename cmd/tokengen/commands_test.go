@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const spec = "rate_limit: 5\nallowed_routes: [/api/v1/users/*]\nduration: 3600\n"
+
+// newService returns a token service backed by miniredis
+func newService(t *testing.T) tokenservice.Service {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return tokenservice.NewWithClient(client, "pepper")
+}
+
+// run parses args for the command and runs it, returning what it printed
+func run(t *testing.T, setup func(*flag.FlagSet) runFunc, svc tokenservice.Service, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	stdout = &out
+	t.Cleanup(func() { stdout = os.Stdout })
+	fs := flag.NewFlagSet("tokengen", flag.ContinueOnError)
+	runCmd := setup(fs)
+	require.NoError(t, fs.Parse(args))
+	err := runCmd(context.Background(), svc)
+	return out.String(), err
+}
+
+func writeSpec(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokendata.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(spec), 0o600))
+	return path
+}
+
+func TestCreateAndGet(t *testing.T) {
+	svc := newService(t)
+
+	out, err := run(t, createCmd, svc, "-file", writeSpec(t))
+	require.NoError(t, err)
+	_, apiKey, found := strings.Cut(out, "API Key: ")
+	require.True(t, found, out)
+	apiKey = strings.TrimSpace(apiKey)
+
+	out, err = run(t, getCmd, svc, "-key", apiKey)
+	require.NoError(t, err)
+	var token models.TokenData
+	require.NoError(t, json.Unmarshal([]byte(out), &token))
+	assert.Equal(t, 5, token.RateLimit)
+	assert.Equal(t, []string{"/api/v1/users/*"}, token.AllowedRoutes)
+	assert.Equal(t, tokenservice.HashAPIKey("pepper", apiKey), token.KeyHash)
+
+	_, err = run(t, getCmd, svc, "-key", "unknown")
+	assert.EqualError(t, err, "token unknown not found")
+}
+
+func TestUpdateAndRevokeByKeyHash(t *testing.T) {
+	svc := newService(t)
+	_, err := run(t, createCmd, svc, "-file", writeSpec(t))
+	require.NoError(t, err)
+
+	out, err := run(t, listCmd, svc)
+	require.NoError(t, err)
+	var tokens []models.TokenData
+	require.NoError(t, json.Unmarshal([]byte(out), &tokens))
+	require.Len(t, tokens, 1)
+	keyHash := tokens[0].KeyHash
+
+	out, err = run(t, updateCmd, svc, "-key", keyHash, "-rate-limit", "10")
+	require.NoError(t, err)
+	var token models.TokenData
+	require.NoError(t, json.Unmarshal([]byte(out), &token))
+	assert.Equal(t, 10, token.RateLimit)
+	assert.Equal(t, keyHash, token.KeyHash)
+
+	out, err = run(t, revokeCmd, svc, "-key", keyHash)
+	require.NoError(t, err)
+	assert.Contains(t, out, "revoked")
+	_, err = run(t, getCmd, svc, "-key", keyHash)
+	assert.EqualError(t, err, "token "+keyHash+" not found")
+}
+
+func TestBulkCreate(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		output string
+		read   func(t *testing.T, data []byte) []string
+	}{
+		{
+			desc:   "Test csv output",
+			output: "tokens.csv",
+			read: func(t *testing.T, data []byte) []string {
+				rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+				require.NoError(t, err)
+				assert.Equal(t, []string{"api_key", "rate_limit", "expires_at", "allowed_routes"}, rows[0])
+				var keys []string
+				for _, row := range rows[1:] {
+					keys = append(keys, row[0])
+				}
+				return keys
+			},
+		},
+		{
+			desc:   "Test json output",
+			output: "tokens.json",
+			read: func(t *testing.T, data []byte) []string {
+				var records []tokenRecord
+				require.NoError(t, json.Unmarshal(data, &records))
+				var keys []string
+				for _, r := range records {
+					keys = append(keys, r.APIKey)
+				}
+				return keys
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			svc := newService(t)
+			output := filepath.Join(t.TempDir(), tC.output)
+
+			_, err := run(t, bulkCreateCmd, svc, "-file", writeSpec(t), "-n", "3", "-output", output)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(output)
+			require.NoError(t, err)
+			keys := tC.read(t, data)
+			require.Len(t, keys, 3)
+			for _, key := range keys {
+				_, err := svc.GetToken(context.Background(), key)
+				assert.NoError(t, err, "key %s is stored", key)
+			}
+		})
+	}
+}
+
+// failingService fails to store tokens after the first `stored`
+type failingService struct {
+	tokenservice.Service
+	stored int
+}
+
+func (s *failingService) StoreToken(ctx context.Context, token models.TokenData) error {
+	if s.stored == 0 {
+		return errors.New("connection refused")
+	}
+	s.stored--
+	return s.Service.StoreToken(ctx, token)
+}
+
+func TestBulkCreate_RevokesStoredTokensOnFailure(t *testing.T) {
+	svc := newService(t)
+	output := filepath.Join(t.TempDir(), "tokens.csv")
+
+	_, err := run(t, bulkCreateCmd, &failingService{Service: svc, stored: 2}, "-file", writeSpec(t), "-n", "3", "-output", output)
+	assert.ErrorContains(t, err, "storing token 3 of 3: connection refused")
+
+	tokens, err := svc.ListTokens(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, tokens, "tokens whose keys were not written are revoked")
+	assert.NoFileExists(t, output, "the output can be retried")
+}
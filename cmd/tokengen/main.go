@@ -2,52 +2,60 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"time"
+	"os"
 
 	"github.com/arjunksofficial/tyk-task/internal/config"
 	"github.com/arjunksofficial/tyk-task/internal/rediscli"
-	"github.com/arjunksofficial/tyk-task/internal/token/models"
-	"github.com/google/uuid"
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
 )
 
 func main() {
-	ctx := context.Background()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name, args := os.Args[1], os.Args[2:]
+	if name == "-h" || name == "--help" || name == "help" {
+		usage()
+		return
+	}
 
-	config.GetConfig() // Connect to Redis
-	redisCli := rediscli.GetRedisClient()
-	defer func() {
-		if err := rediscli.CloseRedisClient(); err != nil {
-			log.Fatalf("Failed to close Redis client: %v", err)
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
 		}
-	}()
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
 
-	// Generate a UUID-based API Key
-	apiKey := uuid.New().String()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	run := cmd.setup(fs)
+	fs.Parse(args)
 
-	// Define the token metadata
-	token := models.TokenData{
-		APIKey:        apiKey,
-		RateLimit:     5,
-		ExpiresAt:     time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
-		AllowedRoutes: []string{"/api/v1/users/*", "/api/v1/products/*", "/api/v1/orders/*"},
+	config.GetConfig() // Connect to Redis
+	err := run(context.Background(), tokenservice.New())
+	if closeErr := rediscli.CloseRedisClient(); closeErr != nil {
+		log.Printf("Failed to close Redis client: %v", closeErr)
 	}
-
-	// Convert to JSON for storage
-	jsonData, err := json.Marshal(token)
 	if err != nil {
-		log.Fatalf("Failed to marshal token: %v", err)
+		log.Fatalf("%s: %v", name, err)
 	}
+}
 
-	// Store in Redis under the key: "token:<api_key>"
-	key := fmt.Sprintf("token:%s", apiKey)
-	err = redisCli.Set(ctx, key, jsonData, 0).Err() // No TTL at Redis level (we handle expiry in code)
-	if err != nil {
-		log.Fatalf("Failed to store token in Redis: %v", err)
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: tokengen <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.usage)
 	}
-
-	fmt.Println("✅ Token generated and stored in Redis:")
-	fmt.Printf("API Key: %s\n", apiKey)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run tokengen <command> -h for the flags of a command.")
 }
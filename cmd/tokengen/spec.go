@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/ratelimit"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"gopkg.in/yaml.v3"
+)
+
+// tokenSpec describes the tokens to generate, read from tokendata.yaml
+type tokenSpec struct {
+	RateLimit          int      `yaml:"rate_limit"`
+	AllowedRoutes      []string `yaml:"allowed_routes"`
+	Duration           int64    `yaml:"duration"` // seconds
+	RateLimitAlgorithm string   `yaml:"rate_limit_algorithm"`
+	BurstCapacity      int      `yaml:"burst_capacity"`
+	RefillRate         float64  `yaml:"refill_rate"`
+}
+
+// specFlags are the flags that override fields of the spec file
+type specFlags struct {
+	file          string
+	rateLimit     int
+	allowedRoutes string
+	duration      int64
+	algorithm     string
+	burstCapacity int
+	refillRate    float64
+}
+
+func (f *specFlags) register(fs *flag.FlagSet, withFile bool) {
+	if withFile {
+		fs.StringVar(&f.file, "file", "tokendata.yaml", "path to the token spec file")
+	}
+	fs.IntVar(&f.rateLimit, "rate-limit", 0, "requests per minute")
+	fs.StringVar(&f.allowedRoutes, "routes", "", "comma separated allowed routes, e.g. /api/v1/users/*")
+	fs.Int64Var(&f.duration, "duration", 0, "token lifetime in seconds")
+	fs.StringVar(&f.algorithm, "algorithm", "", "rate limit algorithm")
+	fs.IntVar(&f.burstCapacity, "burst", 0, "token bucket capacity")
+	fs.Float64Var(&f.refillRate, "refill-rate", 0, "token bucket refill rate per second")
+}
+
+// loadSpec reads the spec file and applies the flags set on the command line
+func (f *specFlags) loadSpec(fs *flag.FlagSet) (tokenSpec, error) {
+	var spec tokenSpec
+	data, err := os.ReadFile(f.file)
+	if err != nil {
+		return spec, fmt.Errorf("reading %s: %w", f.file, err)
+	}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("parsing %s: %w", f.file, err)
+	}
+	f.override(fs, &spec)
+	if spec.Duration <= 0 {
+		return spec, fmt.Errorf("duration must be positive, got %d", spec.Duration)
+	}
+	if err := ratelimit.ValidateToken(spec.newToken("")); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// override copies only the flags that were explicitly set onto the spec
+func (f *specFlags) override(fs *flag.FlagSet, spec *tokenSpec) {
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "rate-limit":
+			spec.RateLimit = f.rateLimit
+		case "routes":
+			spec.AllowedRoutes = splitRoutes(f.allowedRoutes)
+		case "duration":
+			spec.Duration = f.duration
+		case "algorithm":
+			spec.RateLimitAlgorithm = f.algorithm
+		case "burst":
+			spec.BurstCapacity = f.burstCapacity
+		case "refill-rate":
+			spec.RefillRate = f.refillRate
+		}
+	})
+}
+
+// applyTo updates an existing token with the flags that were set
+func (f *specFlags) applyTo(fs *flag.FlagSet, token *models.TokenData) {
+	spec := tokenSpec{
+		RateLimit:          token.RateLimit,
+		AllowedRoutes:      token.AllowedRoutes,
+		RateLimitAlgorithm: token.RateLimitAlgorithm,
+		BurstCapacity:      token.BurstCapacity,
+		RefillRate:         token.RefillRate,
+	}
+	f.override(fs, &spec)
+	token.SetRateLimit(spec.RateLimit)
+	token.SetAllowedRoutes(spec.AllowedRoutes)
+	token.RateLimitAlgorithm = spec.RateLimitAlgorithm
+	token.SetBucket(spec.BurstCapacity, spec.RefillRate)
+	if spec.Duration > 0 {
+		token.SetExpiry(spec.Duration)
+	}
+}
+
+// newToken builds a token for the given key from the spec
+func (s tokenSpec) newToken(apiKey string) models.TokenData {
+	token := models.NewToken(apiKey)
+	token.SetRateLimit(s.RateLimit)
+	token.SetAllowedRoutes(s.AllowedRoutes)
+	token.SetExpiry(s.Duration)
+	token.RateLimitAlgorithm = s.RateLimitAlgorithm
+	token.SetBucket(s.BurstCapacity, s.RefillRate)
+	return *token
+}
+
+func splitRoutes(routes string) []string {
+	result := []string{}
+	for _, route := range strings.Split(routes, ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			result = append(result, route)
+		}
+	}
+	return result
+}
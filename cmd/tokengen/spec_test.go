@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecFlags_LoadSpec(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		content      string
+		args         []string
+		expectedSpec tokenSpec
+		wantErr      string
+	}{
+		{
+			desc:         "Test spec file",
+			content:      "rate_limit: 5\nallowed_routes: [/api/v1/users/*]\nduration: 3600\n",
+			expectedSpec: tokenSpec{RateLimit: 5, AllowedRoutes: []string{"/api/v1/users/*"}, Duration: 3600},
+		},
+		{
+			desc:    "Test flags override the file",
+			content: "rate_limit: 5\nallowed_routes: [/api/v1/users/*]\nduration: 3600\n",
+			args:    []string{"-rate-limit", "10", "-routes", "/api/v1/orders/*, /api/v1/users/*", "-algorithm", "token_bucket", "-burst", "20", "-refill-rate", "0.5"},
+			expectedSpec: tokenSpec{
+				RateLimit:          10,
+				AllowedRoutes:      []string{"/api/v1/orders/*", "/api/v1/users/*"},
+				Duration:           3600,
+				RateLimitAlgorithm: "token_bucket",
+				BurstCapacity:      20,
+				RefillRate:         0.5,
+			},
+		},
+		{
+			desc:    "Test duration required",
+			content: "rate_limit: 5\n",
+			wantErr: "duration must be positive, got 0",
+		},
+		{
+			desc:    "Test bucket that never refills",
+			content: "rate_limit: 0\nburst_capacity: 10\nduration: 3600\n",
+			wantErr: "token bucket needs a positive refill_rate or rate_limit",
+		},
+		{
+			desc:    "Test unknown algorithm",
+			content: "rate_limit: 5\nduration: 3600\n",
+			args:    []string{"-algorithm", "leaky_bucket"},
+			wantErr: `unknown rate_limit_algorithm "leaky_bucket"`,
+		},
+		{
+			desc:    "Test invalid yaml",
+			content: "rate_limit: [5\n",
+			wantErr: "parsing",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokendata.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tC.content), 0o600))
+			fs := flag.NewFlagSet("create", flag.ContinueOnError)
+			var sf specFlags
+			sf.register(fs, true)
+			require.NoError(t, fs.Parse(append([]string{"-file", path}, tC.args...)))
+
+			spec, err := sf.loadSpec(fs)
+			if tC.wantErr != "" {
+				assert.ErrorContains(t, err, tC.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tC.expectedSpec, spec)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/ratelimit"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultDuration is the token lifetime used when a create request sets neither
+// duration nor expires_at
+const DefaultDuration = 24 * time.Hour
+
+// Handler exposes token management over HTTP under /admin/tokens
+type Handler struct {
+	TokenService tokenservice.Service
+	Secret       string
+}
+
+// NewHandler creates a new admin Handler protected by the given secret,
+// storing tokens through redisClient with keys hashed with pepper
+func NewHandler(secret string, redisClient *redis.Client, pepper string) *Handler {
+	return &Handler{
+		TokenService: tokenservice.NewWithClient(redisClient, pepper),
+		Secret:       secret,
+	}
+}
+
+// tokenRequest is the body accepted by create and update. Fields left out
+// of an update keep their current value.
+type tokenRequest struct {
+	RateLimit          *int     `json:"rate_limit"`
+	AllowedRoutes      []string `json:"allowed_routes"`
+	ExpiresAt          *string  `json:"expires_at"`
+	Duration           *int64   `json:"duration"` // seconds from now
+	RateLimitAlgorithm *string  `json:"rate_limit_algorithm"`
+	BurstCapacity      *int     `json:"burst_capacity"`
+	RefillRate         *float64 `json:"refill_rate"`
+}
+
+// Register mounts the admin API on the router
+func (h *Handler) Register(router *mux.Router) {
+	sub := router.PathPrefix("/admin").Subrouter()
+	sub.Use(h.authenticate)
+	sub.HandleFunc("/tokens", h.createToken).Methods(http.MethodPost).Name("AdminCreateToken")
+	sub.HandleFunc("/tokens", h.listTokens).Methods(http.MethodGet).Name("AdminListTokens")
+	sub.HandleFunc("/tokens/{key}", h.getToken).Methods(http.MethodGet).Name("AdminGetToken")
+	sub.HandleFunc("/tokens/{key}", h.updateToken).Methods(http.MethodPut, http.MethodPatch).Name("AdminUpdateToken")
+	sub.HandleFunc("/tokens/{key}", h.deleteToken).Methods(http.MethodDelete).Name("AdminDeleteToken")
+}
+
+// authenticate checks the admin secret sent as a bearer token
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.Secret)) != 1 {
+			requestid.Error(w, r, "Unauthorized: Invalid admin secret", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) createToken(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		requestid.Error(w, r, "Bad Request: Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	token := models.NewToken(uuid.New().String())
+	token.SetExpiry(int64(DefaultDuration.Seconds()))
+	if err := req.apply(token); err != nil {
+		requestid.Error(w, r, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.TokenService.StoreToken(r.Context(), *token); err != nil {
+		log.Printf("Failed to store token: %v [request_id=%s]", err, requestid.FromContext(r.Context()))
+		requestid.Error(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, token)
+}
+
+func (h *Handler) listTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.TokenService.ListTokens(r.Context())
+	if err != nil {
+		log.Printf("Failed to list tokens: %v [request_id=%s]", err, requestid.FromContext(r.Context()))
+		requestid.Error(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+func (h *Handler) getToken(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, token)
+}
+
+func (h *Handler) updateToken(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		requestid.Error(w, r, "Bad Request: Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(&token); err != nil {
+		requestid.Error(w, r, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.TokenService.StoreToken(r.Context(), token); err != nil {
+		log.Printf("Failed to store token: %v [request_id=%s]", err, requestid.FromContext(r.Context()))
+		requestid.Error(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, token)
+}
+
+func (h *Handler) deleteToken(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	if err := h.TokenService.DeleteTokenByHash(r.Context(), token.KeyHash); err != nil {
+		log.Printf("Failed to delete token: %v [request_id=%s]", err, requestid.FromContext(r.Context()))
+		requestid.Error(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup fetches the token named in the path by its API key or by the key
+// hash returned by list, writing the error response when it cannot
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request) (models.TokenData, bool) {
+	key := mux.Vars(r)["key"]
+	token, err := h.TokenService.GetToken(r.Context(), key)
+	if errors.Is(err, redis.Nil) {
+		token, err = h.TokenService.GetTokenByHash(r.Context(), key)
+	}
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			requestid.Error(w, r, "Not Found: Token does not exist", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to get token: %v [request_id=%s]", err, requestid.FromContext(r.Context()))
+			requestid.Error(w, r, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return models.TokenData{}, false
+	}
+	return token, true
+}
+
+// apply copies the fields set in the request onto the token
+func (req tokenRequest) apply(token *models.TokenData) error {
+	if req.RateLimit != nil {
+		if *req.RateLimit < 0 {
+			return errors.New("rate_limit must not be negative")
+		}
+		token.SetRateLimit(*req.RateLimit)
+	}
+	if req.AllowedRoutes != nil {
+		token.SetAllowedRoutes(req.AllowedRoutes)
+	}
+	if req.ExpiresAt != nil && req.Duration != nil {
+		return errors.New("set either expires_at or duration")
+	}
+	if req.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			return errors.New("expires_at must be an RFC3339 timestamp")
+		}
+		token.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	}
+	if req.Duration != nil {
+		if *req.Duration <= 0 {
+			return errors.New("duration must be positive")
+		}
+		token.SetExpiry(*req.Duration)
+	}
+	if req.RateLimitAlgorithm != nil {
+		token.RateLimitAlgorithm = *req.RateLimitAlgorithm
+	}
+	if req.BurstCapacity != nil || req.RefillRate != nil {
+		capacity, rate := token.BurstCapacity, token.RefillRate
+		if req.BurstCapacity != nil {
+			capacity = *req.BurstCapacity
+		}
+		if req.RefillRate != nil {
+			rate = *req.RefillRate
+		}
+		if capacity < 0 || rate < 0 {
+			return errors.New("burst_capacity and refill_rate must not be negative")
+		}
+		token.SetBucket(capacity, rate)
+	}
+	return ratelimit.ValidateToken(*token)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
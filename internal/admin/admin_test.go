@@ -0,0 +1,221 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/admin"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandler(t *testing.T) {
+	validTimeStamp := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	existing := models.TokenData{
+		KeyHash:       "existing_key_hash",
+		RateLimit:     5,
+		ExpiresAt:     validTimeStamp,
+		AllowedRoutes: []string{"/api/v1/orders/*"},
+	}
+
+	testCases := []struct {
+		desc           string
+		method         string
+		path           string
+		body           string
+		secret         string
+		mockTokenSvc   func(*services.MockService)
+		expectedStatus int
+		assertBody     func(t *testing.T, body string)
+	}{
+		{
+			desc:           "Test missing admin secret",
+			method:         http.MethodGet,
+			path:           "/admin/tokens",
+			mockTokenSvc:   func(*services.MockService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:           "Test wrong admin secret",
+			method:         http.MethodGet,
+			path:           "/admin/tokens",
+			secret:         "wrong",
+			mockTokenSvc:   func(*services.MockService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:   "Test create token with generated key",
+			method: http.MethodPost,
+			path:   "/admin/tokens",
+			body:   `{"rate_limit": 10, "allowed_routes": ["/api/v1/users/*"], "duration": 3600}`,
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("StoreToken", mock.Anything, mock.MatchedBy(func(token models.TokenData) bool {
+					return token.APIKey != "" && token.RateLimit == 10
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			assertBody: func(t *testing.T, body string) {
+				var token models.TokenData
+				assert.NoError(t, json.Unmarshal([]byte(body), &token))
+				assert.NotEmpty(t, token.APIKey)
+				assert.Equal(t, []string{"/api/v1/users/*"}, token.AllowedRoutes)
+				assert.False(t, token.IsExpired())
+			},
+		},
+		{
+			desc:           "Test create token with invalid expiry",
+			method:         http.MethodPost,
+			path:           "/admin/tokens",
+			body:           `{"expires_at": "tomorrow"}`,
+			secret:         "s3cret",
+			mockTokenSvc:   func(*services.MockService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			desc:           "Test create token bucket that never refills",
+			method:         http.MethodPost,
+			path:           "/admin/tokens",
+			body:           `{"rate_limit": 0, "burst_capacity": 10}`,
+			secret:         "s3cret",
+			mockTokenSvc:   func(*services.MockService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			desc:   "Test list tokens",
+			method: http.MethodGet,
+			path:   "/admin/tokens",
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("ListTokens", mock.Anything).Return([]models.TokenData{existing}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			assertBody: func(t *testing.T, body string) {
+				var tokens []models.TokenData
+				assert.NoError(t, json.Unmarshal([]byte(body), &tokens))
+				assert.Equal(t, []models.TokenData{existing}, tokens)
+			},
+		},
+		{
+			desc:   "Test get unknown token",
+			method: http.MethodGet,
+			path:   "/admin/tokens/unknown_api_key",
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "unknown_api_key").Return(models.TokenData{}, redis.Nil)
+				m.On("GetTokenByHash", mock.Anything, "unknown_api_key").Return(models.TokenData{}, redis.Nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:   "Test get token backend error",
+			method: http.MethodGet,
+			path:   "/admin/tokens/existing_api_key",
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "existing_api_key").Return(models.TokenData{}, errors.New("some error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			desc:   "Test get token by key hash",
+			method: http.MethodGet,
+			path:   "/admin/tokens/existing_key_hash",
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "existing_key_hash").Return(models.TokenData{}, redis.Nil)
+				m.On("GetTokenByHash", mock.Anything, "existing_key_hash").Return(existing, nil)
+			},
+			expectedStatus: http.StatusOK,
+			assertBody: func(t *testing.T, body string) {
+				var token models.TokenData
+				assert.NoError(t, json.Unmarshal([]byte(body), &token))
+				assert.Equal(t, existing, token)
+			},
+		},
+		{
+			desc:   "Test update rate limit keeps other fields",
+			method: http.MethodPatch,
+			path:   "/admin/tokens/existing_api_key",
+			body:   `{"rate_limit": 50}`,
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "existing_api_key").Return(existing, nil)
+				updated := existing
+				updated.RateLimit = 50
+				m.On("StoreToken", mock.Anything, updated).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:   "Test update with unknown algorithm",
+			method: http.MethodPatch,
+			path:   "/admin/tokens/existing_api_key",
+			body:   `{"rate_limit_algorithm": "leaky_bucket"}`,
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "existing_api_key").Return(existing, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			assertBody: func(t *testing.T, body string) {
+				assert.Contains(t, body, `unknown rate_limit_algorithm "leaky_bucket"`)
+			},
+		},
+		{
+			desc:   "Test delete token",
+			method: http.MethodDelete,
+			path:   "/admin/tokens/existing_api_key",
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "existing_api_key").Return(existing, nil)
+				m.On("DeleteTokenByHash", mock.Anything, "existing_key_hash").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			desc:   "Test delete token by key hash",
+			method: http.MethodDelete,
+			path:   "/admin/tokens/existing_key_hash",
+			secret: "s3cret",
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "existing_key_hash").Return(models.TokenData{}, redis.Nil)
+				m.On("GetTokenByHash", mock.Anything, "existing_key_hash").Return(existing, nil)
+				m.On("DeleteTokenByHash", mock.Anything, "existing_key_hash").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mockTokenSvc := services.NewMockService(t)
+			tC.mockTokenSvc(mockTokenSvc)
+
+			router := mux.NewRouter()
+			h := admin.Handler{TokenService: mockTokenSvc, Secret: "s3cret"}
+			h.Register(router)
+
+			req := httptest.NewRequest(tC.method, tC.path, strings.NewReader(tC.body))
+			if tC.secret != "" {
+				req.Header.Set("Authorization", "Bearer "+tC.secret)
+			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+			if tC.assertBody != nil {
+				tC.assertBody(t, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arjunksofficial/tyk-task/internal/audit"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, audit.Init(config.Audit{File: path}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/42", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	r = r.WithContext(requestid.NewContext(r.Context(), "req-1"))
+	audit.Record(r, audit.Event{Route: "orders", Reason: audit.ReasonQuotaExceeded, Status: http.StatusTooManyRequests, KeyHash: "5e884898da28"})
+	require.NoError(t, audit.Close())
+	// dropped once closed
+	audit.Record(r, audit.Event{Route: "orders", Reason: audit.ReasonExpired, Status: http.StatusUnauthorized})
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var event map[string]any
+	require.NoError(t, json.Unmarshal(data, &event))
+	assert.Equal(t, "rejected", event["msg"])
+	assert.Equal(t, "req-1", event["request_id"])
+	assert.Equal(t, "203.0.113.7", event["client_ip"])
+	assert.Equal(t, "GET", event["method"])
+	assert.Equal(t, "/api/v1/orders/42", event["path"])
+	assert.Equal(t, "orders", event["route"])
+	assert.Equal(t, "quota_exceeded", event["reason"])
+	assert.Equal(t, 429.0, event["status"])
+	assert.Equal(t, "5e884898da28", event["api_key"])
+}
+
+func TestInit(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		cfg     config.Audit
+		wantErr string
+	}{
+		{desc: "Test disabled without a file"},
+		{desc: "Test unwritable file", cfg: config.Audit{File: filepath.Join(t.TempDir(), "missing", "audit.log")}, wantErr: "opening audit log"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := audit.Init(tC.cfg)
+			if tC.wantErr != "" {
+				assert.ErrorContains(t, err, tC.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, audit.Close())
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package breaker
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/sony/gobreaker/v2"
+)
+
+// Trip and recovery settings used for a route's circuit_breaker block,
+// and the 503 body sent while the circuit is open
+const (
+	DefaultMinRequests      = 10
+	DefaultWindow           = time.Minute
+	DefaultOpenDuration     = 30 * time.Second
+	DefaultHalfOpenRequests = 1
+	DefaultBody             = "Service Unavailable"
+	// RequestIDPlaceholder in a body with a content type is replaced by
+	// the request ID
+	RequestIDPlaceholder = "{request_id}"
+)
+
+var (
+	errFailed = errors.New("upstream request failed")
+	// errCanceled marks requests the client gave up on, which say nothing
+	// about the upstream
+	errCanceled = errors.New("request canceled")
+)
+
+// Breaker guards a route's upstream with a circuit breaker. While the
+// circuit is open requests are answered with a 503 without reaching the
+// upstream; after OpenDuration a few trial requests decide whether it
+// closes again.
+type Breaker struct {
+	route   string
+	cb      *gobreaker.TwoStepCircuitBreaker[struct{}]
+	latency time.Duration
+	body    string
+	// contentType is empty for plain text bodies
+	contentType string
+}
+
+// New creates the route's circuit breaker, or returns nil when the route
+// does not configure one
+func New(route config.Route) *Breaker {
+	cfg := route.CircuitBreaker
+	if cfg.ErrorRate == 0 {
+		return nil
+	}
+	if cfg.MinRequests == 0 {
+		cfg.MinRequests = DefaultMinRequests
+	}
+	if cfg.Window == 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.OpenDuration == 0 {
+		cfg.OpenDuration = DefaultOpenDuration
+	}
+	if cfg.HalfOpenRequests == 0 {
+		cfg.HalfOpenRequests = DefaultHalfOpenRequests
+	}
+	if cfg.Body == "" {
+		cfg.Body = DefaultBody
+	}
+
+	b := &Breaker{
+		route:       route.GetName(),
+		latency:     cfg.LatencyThreshold,
+		body:        cfg.Body,
+		contentType: cfg.ContentType,
+	}
+	b.cb = gobreaker.NewTwoStepCircuitBreaker[struct{}](gobreaker.Settings{
+		Name:        b.route,
+		MaxRequests: uint32(cfg.HalfOpenRequests),
+		Interval:    cfg.Window,
+		// Roll the window in tenths so the error rate does not drop to
+		// nothing at the end of each window
+		BucketPeriod: cfg.Window / 10,
+		Timeout:      cfg.OpenDuration,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.Requests >= uint32(cfg.MinRequests) &&
+				float64(counts.TotalFailures) >= cfg.ErrorRate*float64(counts.Requests)
+		},
+		IsExcluded: func(err error) bool {
+			return errors.Is(err, errCanceled)
+		},
+		OnStateChange: b.stateChanged,
+	})
+	return b
+}
+
+// Wrap guards next with the circuit breaker
+func (b *Breaker) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done, err := b.cb.Allow()
+		if err != nil {
+			b.unavailable(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := logging.NewResponseWriter(w)
+		completed := false
+		// Deferred so that the request is released even when next panics,
+		// e.g. with http.ErrAbortHandler when the client goes away while the
+		// response is streamed
+		defer func() {
+			switch {
+			case !completed, r.Context().Err() != nil:
+				done(errCanceled)
+			case rw.Status >= http.StatusInternalServerError:
+				done(errFailed)
+			case b.latency > 0 && time.Since(start) > b.latency:
+				done(errFailed)
+			default:
+				done(nil)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+		completed = true
+	})
+}
+
+// Publish exports the current state of the circuit
+func (b *Breaker) Publish() {
+	metrics.CircuitBreakerState.WithLabelValues(b.route).Set(float64(b.cb.State()))
+}
+
+func (b *Breaker) stateChanged(_ string, from, to gobreaker.State) {
+	log.Printf("Circuit breaker of route %s changed from %s to %s", b.route, from, to)
+	metrics.CircuitBreakerTransitions.WithLabelValues(b.route, from.String(), to.String()).Inc()
+	metrics.CircuitBreakerState.WithLabelValues(b.route).Set(float64(to))
+}
+
+// unavailable answers a request while the circuit is open. Plain text
+// bodies get the request ID appended like the gateway's other errors; other
+// bodies carry it where they place RequestIDPlaceholder.
+func (b *Breaker) unavailable(w http.ResponseWriter, r *http.Request) {
+	if b.contentType == "" {
+		requestid.Error(w, r, b.body, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", b.contentType)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	io.WriteString(w, strings.ReplaceAll(b.body, RequestIDPlaceholder, requestid.FromContext(r.Context())))
+}
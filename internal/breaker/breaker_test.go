@@ -0,0 +1,162 @@
+package breaker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/breaker"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upstream answers with the status stored in status and counts its calls
+type upstream struct {
+	status atomic.Int64
+	delay  time.Duration
+	calls  atomic.Int64
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.calls.Add(1)
+	time.Sleep(u.delay)
+	w.WriteHeader(int(u.status.Load()))
+}
+
+func serve(ctx context.Context, h http.Handler) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/orders/", nil).WithContext(ctx))
+	return rr
+}
+
+func TestNew_Disabled(t *testing.T) {
+	assert.Nil(t, breaker.New(config.Route{Path: "/api/v1/orders/"}))
+}
+
+func TestBreaker(t *testing.T) {
+	up := &upstream{}
+	up.status.Store(http.StatusInternalServerError)
+	b := breaker.New(config.Route{
+		Name: "orders-breaker",
+		CircuitBreaker: config.CircuitBreaker{
+			ErrorRate:    0.5,
+			MinRequests:  4,
+			OpenDuration: 50 * time.Millisecond,
+			Body:         `{"error":"orders unavailable","request_id":"{request_id}"}`,
+			ContentType:  "application/json",
+		},
+	})
+	require.NotNil(t, b)
+	b.Publish()
+	handler := b.Wrap(up)
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	state := func() float64 {
+		return testutil.ToFloat64(metrics.CircuitBreakerState.WithLabelValues("orders-breaker"))
+	}
+	assert.Equal(t, 0.0, state())
+
+	// Half of the requests fail, but only the fourth reaches min_requests
+	for i, status := range []int{http.StatusOK, http.StatusInternalServerError, http.StatusOK} {
+		up.status.Store(int64(status))
+		assert.Equal(t, status, serve(ctx, handler).Code, i)
+	}
+	assert.Equal(t, 0.0, state())
+	up.status.Store(http.StatusBadGateway)
+	assert.Equal(t, http.StatusBadGateway, serve(ctx, handler).Code)
+	assert.Equal(t, 2.0, state())
+
+	// Open: answered without reaching the upstream
+	calls := up.calls.Load()
+	rr := serve(ctx, handler)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, `{"error":"orders unavailable","request_id":"req-1"}`, rr.Body.String())
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, calls, up.calls.Load())
+
+	// Half-open: a successful trial closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	up.status.Store(http.StatusOK)
+	assert.Equal(t, http.StatusOK, serve(ctx, handler).Code)
+	assert.Equal(t, 0.0, state())
+
+	transitions := func(from, to string) float64 {
+		return testutil.ToFloat64(metrics.CircuitBreakerTransitions.WithLabelValues("orders-breaker", from, to))
+	}
+	assert.Equal(t, 1.0, transitions("closed", "open"))
+	assert.Equal(t, 1.0, transitions("open", "half-open"))
+	assert.Equal(t, 1.0, transitions("half-open", "closed"))
+}
+
+func TestBreaker_Latency(t *testing.T) {
+	up := &upstream{delay: 20 * time.Millisecond}
+	up.status.Store(http.StatusOK)
+	b := breaker.New(config.Route{
+		Name: "slow",
+		CircuitBreaker: config.CircuitBreaker{
+			ErrorRate:        1,
+			MinRequests:      2,
+			LatencyThreshold: 5 * time.Millisecond,
+		},
+	})
+	handler := b.Wrap(up)
+	ctx := requestid.NewContext(context.Background(), "req-2")
+
+	assert.Equal(t, http.StatusOK, serve(ctx, handler).Code)
+	assert.Equal(t, http.StatusOK, serve(ctx, handler).Code)
+	rr := serve(ctx, handler)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, breaker.DefaultBody+" (request ID: req-2)\n", rr.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+}
+
+func TestBreaker_ClientCanceled(t *testing.T) {
+	up := &upstream{}
+	up.status.Store(http.StatusBadGateway)
+	b := breaker.New(config.Route{
+		Name:           "canceled",
+		CircuitBreaker: config.CircuitBreaker{ErrorRate: 1, MinRequests: 1},
+	})
+	handler := b.Wrap(up)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 3 {
+		serve(ctx, handler)
+	}
+	assert.Equal(t, int64(3), up.calls.Load(), "canceled requests do not trip the circuit")
+}
+
+func TestBreaker_ClientAborted(t *testing.T) {
+	up := &upstream{}
+	up.status.Store(http.StatusBadGateway)
+	var abort atomic.Bool
+	b := breaker.New(config.Route{
+		Name: "aborted",
+		CircuitBreaker: config.CircuitBreaker{
+			ErrorRate: 1, MinRequests: 1, OpenDuration: 10 * time.Millisecond, HalfOpenRequests: 1,
+		},
+	})
+	handler := b.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if abort.Load() {
+			// as the reverse proxy does when the client goes away mid response
+			panic(http.ErrAbortHandler)
+		}
+		up.ServeHTTP(w, r)
+	}))
+
+	serve(context.Background(), handler)
+	time.Sleep(20 * time.Millisecond)
+	abort.Store(true)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { serve(context.Background(), handler) })
+
+	abort.Store(false)
+	up.status.Store(http.StatusOK)
+	assert.Equal(t, http.StatusOK, serve(context.Background(), handler).Code, "the aborted probe is released")
+}
@@ -1,25 +1,400 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
 
 type Config struct {
 	App struct {
 		Name string `json:"name"`
 		Port string `json:"port"`
+		// Server timeouts, see http.Server. WriteTimeout must leave room
+		// for the slowest route's request timeout.
+		ReadHeaderTimeout time.Duration `json:"read_header_timeout" mapstructure:"read_header_timeout"`
+		ReadTimeout       time.Duration `json:"read_timeout" mapstructure:"read_timeout"`
+		WriteTimeout      time.Duration `json:"write_timeout" mapstructure:"write_timeout"`
+		IdleTimeout       time.Duration `json:"idle_timeout" mapstructure:"idle_timeout"`
+		// DrainPeriod is how long the gateway keeps serving, while failing
+		// its readiness check, after SIGTERM or SIGINT. It gives load
+		// balancers time to stop sending new requests.
+		DrainPeriod time.Duration `json:"drain_period" mapstructure:"drain_period"`
+		// ShutdownTimeout bounds the wait for requests in flight once
+		// draining is over
+		ShutdownTimeout time.Duration `json:"shutdown_timeout" mapstructure:"shutdown_timeout"`
 	} `json:"app"`
-	Routes []struct {
-		Path string `json:"path"`
-		Host string `json:"host"`
-	} `json:"routes"`
-	Redis struct {
+	Routes []Route `json:"routes"`
+	Redis  struct {
 		Host     string `json:"host"`
 		Port     string `json:"port"`
 		DB       int    `json:"db"`
 		Password string `json:"password"`
 	} `json:"redis"`
+	Admin struct {
+		// Secret protects the /admin API; the API is disabled when empty
+		Secret string `json:"secret"`
+	} `json:"admin"`
+	Security struct {
+		// APIKeyPepper is the HMAC key API keys are hashed with before they
+		// are stored. Changing it invalidates every stored token.
+		APIKeyPepper string `json:"api_key_pepper" mapstructure:"api_key_pepper"`
+	} `json:"security"`
+	JWT JWT `json:"jwt"`
+	TLS TLS `json:"tls"`
+	// AccessLog is rebuilt with the routes on reload
+	AccessLog AccessLog `json:"access_log" mapstructure:"access_log"`
+	RequestID RequestID `json:"request_id" mapstructure:"request_id"`
+	// Tracing is read at startup only
+	Tracing Tracing `json:"tracing"`
+	Metrics Metrics `json:"metrics"`
+	// Audit is read at startup only
+	Audit Audit `json:"audit"`
+}
+
+// Audit configures the log of rejected requests kept for security review.
+// Every request refused by authentication or rate limiting is written to
+// File as a JSON object; nothing is written when File is empty.
+type Audit struct {
+	File string `json:"file"`
+	// MaxSizeMB is the size at which the file is rotated, 100 when omitted
+	MaxSizeMB int `json:"max_size_mb" mapstructure:"max_size_mb"`
+	// MaxBackups is the number of rotated files kept, 10 when omitted
+	MaxBackups int `json:"max_backups" mapstructure:"max_backups"`
+	// MaxAgeDays removes rotated files older than that many days; age is
+	// not considered when omitted
+	MaxAgeDays int `json:"max_age_days" mapstructure:"max_age_days"`
+	// Compress gzips rotated files
+	Compress bool `json:"compress"`
+}
+
+// Metrics configures the labels of the Prometheus metrics
+type Metrics struct {
+	// TokenLabel labels rate limit rejections with the API key hash. Every
+	// token adds a series, so it is off by default.
+	TokenLabel bool `json:"token_label" mapstructure:"token_label"`
+}
+
+// Tracing configures OpenTelemetry tracing. W3C traceparent headers are
+// always propagated to upstreams; spans are only exported when Endpoint is
+// set.
+type Tracing struct {
+	// Endpoint is the OTLP/HTTP collector, e.g. http://otel-collector:4318
+	Endpoint string `json:"endpoint"`
+	// Headers are sent with every export, e.g. the API key of a hosted
+	// backend
+	Headers map[string]string `json:"headers"`
+	// SampleRatio is the fraction of new traces recorded, 1 when omitted.
+	// Requests continuing a sampled trace are always recorded.
+	SampleRatio float64 `json:"sample_ratio" mapstructure:"sample_ratio"`
+	// ServiceName identifies the gateway in traces, apigw when omitted
+	ServiceName string `json:"service_name" mapstructure:"service_name"`
+}
+
+// RequestID configures the correlation ID of requests
+type RequestID struct {
+	// Header carries the ID from clients and to upstreams, X-Request-ID
+	// when omitted
+	Header string `json:"header"`
+}
+
+// AccessLog configures the JSON access log written to stdout
+type AccessLog struct {
+	// SampleRate is the fraction of requests logged, 1 when omitted.
+	// Requests answered with a 4xx or 5xx status are always logged.
+	SampleRate float64 `json:"sample_rate" mapstructure:"sample_rate"`
+	// Redact lists the fields logged as "[REDACTED]", e.g. client_ip
+	Redact []string `json:"redact"`
+}
+
+// TLS configures the HTTPS listener. The gateway serves plain HTTP when
+// CertFile is empty. It is read at startup only, but the certificate is
+// reloaded when its files change.
+type TLS struct {
+	CertFile string `json:"cert_file" mapstructure:"cert_file"`
+	KeyFile  string `json:"key_file" mapstructure:"key_file"`
+	// MinVersion is 1.2 (default) or 1.3
+	MinVersion string `json:"min_version" mapstructure:"min_version"`
+	// CipherSuites restricts the TLS 1.2 cipher suites by their Go name,
+	// e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. TLS 1.3 suites are not
+	// configurable.
+	CipherSuites []string `json:"cipher_suites" mapstructure:"cipher_suites"`
+	// ClientCAFile verifies the client certificates mtls routes
+	// authenticate with. Clients without a certificate are still accepted.
+	ClientCAFile string `json:"client_ca_file" mapstructure:"client_ca_file"`
+	// RedirectPort, when set, serves plain HTTP redirecting to HTTPS
+	RedirectPort string `json:"redirect_port" mapstructure:"redirect_port"`
+}
+
+// Enabled reports whether the gateway serves HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Version returns the minimum TLS version
+func (t TLS) Version() (uint16, error) {
+	switch t.MinVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, use 1.2 or 1.3", t.MinVersion)
+}
+
+// CipherSuiteIDs resolves CipherSuites. Only suites without known security
+// issues are accepted.
+func (t TLS) CipherSuiteIDs() ([]uint16, error) {
+	var ids []uint16
+	var errs []error
+	for _, name := range t.CipherSuites {
+		i := slices.IndexFunc(tls.CipherSuites(), func(suite *tls.CipherSuite) bool {
+			return suite.Name == name
+		})
+		if i < 0 {
+			errs = append(errs, fmt.Errorf("unknown or insecure cipher suite %q", name))
+			continue
+		}
+		ids = append(ids, tls.CipherSuites()[i].ID)
+	}
+	return ids, errors.Join(errs...)
+}
+
+// JWT configures validation of bearer JWTs. HS256 tokens are checked with
+// HMACSecret, RS256 and ES256 tokens with the keys of JWKSFile.
+type JWT struct {
+	HMACSecret string `json:"hmac_secret" mapstructure:"hmac_secret"`
+	JWKSFile   string `json:"jwks_file" mapstructure:"jwks_file"`
+	Issuer     string `json:"issuer"`
+	Audience   string `json:"audience"`
+}
+
+// Route describes a single upstream exposed by the gateway
+type Route struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Host is the upstream of a route with a single target; routes served
+	// by several use Targets instead
+	Host           string         `json:"host"`
+	Targets        []Target       `json:"targets"`
+	LoadBalancing  LoadBalancing  `json:"load_balancing" mapstructure:"load_balancing"`
+	HealthCheck    HealthCheck    `json:"health_check" mapstructure:"health_check"`
+	CircuitBreaker CircuitBreaker `json:"circuit_breaker" mapstructure:"circuit_breaker"`
+	Timeouts       Timeouts       `json:"timeouts"`
+	Retries        Retries        `json:"retries"`
+	Transport      Transport      `json:"transport"`
+	TLS            UpstreamTLS    `json:"tls"`
+	// Middlewares is the ordered chain wrapped around the route's proxy,
+	// the first entry being the outermost. When omitted DefaultMiddlewares
+	// is used; an explicit empty list exposes the route publicly.
+	Middlewares []string `json:"middlewares"`
+	RateLimit   struct {
+		// Algorithm is used for tokens that do not select their own
+		Algorithm string `json:"algorithm"`
+	} `json:"rate_limit" mapstructure:"rate_limit"`
+	Auth Auth `json:"auth"`
+}
+
+// Target is one upstream server of a route
+type Target struct {
+	URL string `json:"url"`
+	// Weight is the target's share of requests relative to the others,
+	// 1 when omitted
+	Weight int `json:"weight"`
+}
+
+// LoadBalancing selects how requests are spread over a route's targets
+type LoadBalancing struct {
+	// Strategy is one of round_robin (default), weighted,
+	// least_connections or consistent_hash
+	Strategy string `json:"strategy"`
+	// HashOn is what consistent_hash keys on: api_key (default) or header
+	HashOn string `json:"hash_on" mapstructure:"hash_on"`
+	// Header is hashed when HashOn is header
+	Header string `json:"header"`
+}
+
+// HealthCheck decides which targets of a route are kept in rotation
+type HealthCheck struct {
+	Active  ActiveHealthCheck  `json:"active"`
+	Passive PassiveHealthCheck `json:"passive"`
+}
+
+// ActiveHealthCheck probes every target with a GET of Path. A 2xx or 3xx
+// response counts as a success. Active checks are disabled when Path is
+// empty.
+type ActiveHealthCheck struct {
+	Path string `json:"path"`
+	// Interval between probes, 10s when omitted
+	Interval time.Duration `json:"interval"`
+	// Timeout of a probe, 2s when omitted
+	Timeout time.Duration `json:"timeout"`
+	// HealthyThreshold consecutive successes return a target to rotation,
+	// 2 when omitted
+	HealthyThreshold int `json:"healthy_threshold" mapstructure:"healthy_threshold"`
+	// UnhealthyThreshold consecutive failures remove it, 3 when omitted
+	UnhealthyThreshold int `json:"unhealthy_threshold" mapstructure:"unhealthy_threshold"`
+}
+
+// PassiveHealthCheck ejects a target after UnhealthyThreshold consecutive
+// proxied requests fail with a 5xx or a connection error. Passive checks
+// are disabled when UnhealthyThreshold is 0.
+type PassiveHealthCheck struct {
+	UnhealthyThreshold int `json:"unhealthy_threshold" mapstructure:"unhealthy_threshold"`
+	// EjectionDuration the target stays out of rotation, 30s when omitted
+	EjectionDuration time.Duration `json:"ejection_duration" mapstructure:"ejection_duration"`
+}
+
+// CircuitBreaker stops proxying to a route's upstream while too many of its
+// requests fail, answering 503 right away instead. It is disabled when
+// ErrorRate is 0.
+type CircuitBreaker struct {
+	// ErrorRate is the share of failed requests, between 0 and 1, that
+	// opens the circuit. 5xx responses, connection errors and responses
+	// slower than LatencyThreshold are failures.
+	ErrorRate float64 `json:"error_rate" mapstructure:"error_rate"`
+	// MinRequests in the Window before the error rate is considered, 10
+	// when omitted
+	MinRequests int `json:"min_requests" mapstructure:"min_requests"`
+	// LatencyThreshold makes slow responses count as failures, off when 0
+	LatencyThreshold time.Duration `json:"latency_threshold" mapstructure:"latency_threshold"`
+	// Window the error rate is measured over, 60s when omitted
+	Window time.Duration `json:"window"`
+	// OpenDuration before a half-open circuit lets trial requests through,
+	// 30s when omitted
+	OpenDuration time.Duration `json:"open_duration" mapstructure:"open_duration"`
+	// HalfOpenRequests trial requests must succeed to close the circuit, 1
+	// when omitted
+	HalfOpenRequests int `json:"half_open_requests" mapstructure:"half_open_requests"`
+	// Body and ContentType of the 503 answered while the circuit is open.
+	// A body with a ContentType has {request_id} replaced by the request ID.
+	Body        string `json:"body"`
+	ContentType string `json:"content_type" mapstructure:"content_type"`
+}
+
+// Timeouts bound the time spent waiting on a route's upstream
+type Timeouts struct {
+	// Connect bounds establishing a connection, 5s when omitted
+	Connect time.Duration `json:"connect"`
+	// ResponseHeader bounds the wait for the response headers once the
+	// request is sent, 30s when omitted
+	ResponseHeader time.Duration `json:"response_header" mapstructure:"response_header"`
+	// Request bounds the whole request including retries and reading the
+	// response body, unbounded when omitted
+	Request time.Duration `json:"request"`
+}
+
+// Retries resends failed requests to the route's upstream. Only idempotent
+// requests without a body are retried, on connection errors and OnStatus
+// responses.
+type Retries struct {
+	// Attempts after the first one, no retries when 0
+	Attempts int `json:"attempts"`
+	// Backoff before the first retry, doubled for each next one, 100ms
+	// when omitted
+	Backoff time.Duration `json:"backoff"`
+	// OnStatus lists the response codes worth retrying, e.g. 502 and 503
+	OnStatus []int `json:"on_status" mapstructure:"on_status"`
+}
+
+// Transport tunes the connection pool to a route's upstream
+type Transport struct {
+	// MaxIdleConnsPerHost kept open to each target, 32 when omitted
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host" mapstructure:"max_idle_conns_per_host"`
+	// IdleConnTimeout closes connections idle for longer, 90s when omitted
+	IdleConnTimeout time.Duration `json:"idle_conn_timeout" mapstructure:"idle_conn_timeout"`
+}
+
+// UpstreamTLS configures TLS to a route's https targets. Files are read
+// when the route is built, so rotated certificates are picked up by the
+// next config reload.
+type UpstreamTLS struct {
+	// CAFile is a PEM bundle verifying the targets instead of the system
+	// roots, e.g. the internal CA
+	CAFile string `json:"ca_file" mapstructure:"ca_file"`
+	// CertFile and KeyFile are the client certificate presented to targets
+	// requiring mutual TLS
+	CertFile string `json:"cert_file" mapstructure:"cert_file"`
+	KeyFile  string `json:"key_file" mapstructure:"key_file"`
+	// ServerName overrides the name verified in the targets' certificates,
+	// which defaults to the target host
+	ServerName string `json:"server_name" mapstructure:"server_name"`
+	// InsecureSkipVerify accepts any certificate. For development only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify" mapstructure:"insecure_skip_verify"`
+}
+
+// Auth is the authentication policy of a route, applied by the auth
+// middleware
+type Auth struct {
+	// Type is one of keyless, api_key (default), basic, jwt or mtls
+	Type string `json:"type"`
+	// Header and QueryParam carry the key for api_key auth
+	Header     string `json:"header"`
+	QueryParam string `json:"query_param" mapstructure:"query_param"`
+	// HtpasswdFile holds the bcrypt hashed users for basic auth
+	HtpasswdFile string `json:"htpasswd_file" mapstructure:"htpasswd_file"`
+	// AllowedSubjects restricts the client certificate common names for mtls
+	AllowedSubjects []string `json:"allowed_subjects" mapstructure:"allowed_subjects"`
+	// RateLimit in requests per minute for basic and mtls identities, which
+	// carry no token data of their own
+	RateLimit int `json:"rate_limit" mapstructure:"rate_limit"`
+}
+
+// DefaultMiddlewares is applied to routes that do not declare a chain
+var DefaultMiddlewares = []string{"auth", "ratelimit"}
+
+// KeylessMiddlewares is applied to keyless routes that do not declare a
+// chain. Without a token there is nothing to rate limit by.
+var KeylessMiddlewares = []string{"auth"}
+
+// GetName returns the route name, falling back to its path
+func (r Route) GetName() string {
+	if r.Name == "" {
+		return r.Path
+	}
+	return r.Name
+}
+
+// GetTargets returns the route's upstream servers, Host being the only one
+// when no targets are listed
+func (r Route) GetTargets() []Target {
+	if len(r.Targets) == 0 {
+		return []Target{{URL: r.Host, Weight: 1}}
+	}
+	targets := make([]Target, len(r.Targets))
+	for i, target := range r.Targets {
+		if target.Weight == 0 {
+			target.Weight = 1
+		}
+		targets[i] = target
+	}
+	return targets
+}
+
+// GetMiddlewares returns the middleware chain declared for the route
+func (r Route) GetMiddlewares() []string {
+	if r.Middlewares == nil {
+		if r.Auth.Type == "keyless" {
+			return KeylessMiddlewares
+		}
+		return DefaultMiddlewares
+	}
+	return r.Middlewares
 }
 
-var cfg *Config
+var (
+	mu  sync.RWMutex
+	cfg *Config
+)
 
 func (c *Config) GetPort() string {
 	if c.App.Port == "" {
@@ -28,18 +403,13 @@ func (c *Config) GetPort() string {
 	return c.App.Port
 }
 
-func (c *Config) GetRoutes() []struct {
-	Path string `json:"path"`
-	Host string `json:"host"`
-} {
+func (c *Config) GetRoutes() []Route {
 	if c.Routes == nil {
-		return []struct {
-			Path string `json:"path"`
-			Host string `json:"host"`
-		}{}
+		return []Route{}
 	}
 	return c.Routes
 }
+
 func (c *Config) GetRedisConfig() struct {
 	Host     string `json:"host"`
 	Port     string `json:"port"`
@@ -51,28 +421,136 @@ func (c *Config) GetRedisConfig() struct {
 
 // config is under cmd/apigw/config/<env>/master.yaml
 
+const (
+	// EnvVar selects the environment whose config is read
+	EnvVar = "APP_ENV"
+	// DefaultEnv is used when no environment is selected
+	DefaultEnv = "local"
+	// EnvPrefix prefixes the environment variables overriding config keys,
+	// e.g. APIGW_REDIS_HOST overrides redis.host
+	EnvPrefix = "APIGW"
+)
+
+var (
+	env  string
+	path string
+)
+
+// SetEnv selects config/<env>/master.yaml, taking precedence over APP_ENV
+func SetEnv(e string) {
+	env = e
+}
+
+// SetPath makes ReadConfig read the config at p regardless of the
+// environment
+func SetPath(p string) {
+	path = p
+}
+
+// Env returns the selected environment
+func Env() string {
+	if env != "" {
+		return env
+	}
+	if e := os.Getenv(EnvVar); e != "" {
+		return e
+	}
+	return DefaultEnv
+}
+
+// File returns the path of the config file ReadConfig reads
+func File() string {
+	if path != "" {
+		return path
+	}
+	return filepath.Join("config", Env(), "master.yaml")
+}
+
 func ReadConfig() (*Config, error) {
-	viper.SetConfigName("master")         // name of config file (without extension)
-	viper.AddConfigPath("./config/local") // path to look for the config file in
-	viper.SetConfigType("yaml")           // or viper.SetConfigType("json") for JSON files
-	if err := viper.ReadInConfig(); err != nil {
+	file := File()
+	c, err := LoadFile(file)
+	if err != nil {
 		return nil, err
 	}
-	if err := viper.Unmarshal(&cfg); err != nil {
+	viper.SetConfigFile(file) // watched by Watch
+	SetConfig(c)
+	return c, nil
+}
+
+// Load re-reads the config file found by ReadConfig into a fresh Config.
+// Unlike ReadConfig it leaves the active config untouched, so a reload can
+// be rejected without affecting the routes being served.
+func Load() (*Config, error) {
+	return LoadFile(viper.ConfigFileUsed())
+}
+
+// LoadFile reads and validates the config at path. Keys that match no
+// config field are errors, and every problem found is reported at once.
+// Environment variables named after a key with the EnvPrefix override it,
+// even when the file leaves the key out. It reads with its own viper
+// instance, so it is safe to call while the file is being watched.
+func LoadFile(path string) (*Config, error) {
+	v := viper.NewWithOptions(
+		viper.ExperimentalBindStruct(),
+		viper.EnvKeyReplacer(strings.NewReplacer(".", "_")),
+	)
+	v.SetEnvPrefix(EnvPrefix)
+	v.AutomaticEnv()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	// Decoding continues past unknown keys, so the rest of the config is
+	// still validated
+	decodeErr := v.UnmarshalExact(c)
+	if err := errors.Join(decodeErr, c.Validate()); err != nil {
 		return nil, err
 	}
-	return cfg, nil
+	return c, nil
 }
 
-func GetConfig() *Config {
-	if cfg == nil {
-		var err error
-		cfg, err = ReadConfig()
-		if err != nil {
-			panic("Failed to read config: " + err.Error())
+// Watch calls onChange whenever the config file is written. Kubernetes
+// configmap updates, which swap a symlink, are picked up as well. The
+// returned stop func waits for a running onChange and drops later changes,
+// as viper cannot stop watching the file itself.
+func Watch(onChange func()) (stop func()) {
+	var mu sync.Mutex
+	stopped := false
+	viper.OnConfigChange(func(fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			onChange()
 		}
+	})
+	viper.WatchConfig()
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+	}
+}
+
+// SetConfig replaces the config returned by GetConfig
+func SetConfig(c *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
+
+func GetConfig() *Config {
+	mu.RLock()
+	c := cfg
+	mu.RUnlock()
+	if c != nil {
+		return c
+	}
+	c, err := ReadConfig()
+	if err != nil {
+		panic("Failed to read config: " + err.Error())
 	}
-	return cfg
+	return c
 }
 
 func (c *Config) IsReady() bool {
@@ -0,0 +1,324 @@
+package config_test
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validConfig = `
+app:
+  port: 9000
+  name: "API Gateway"
+  write_timeout: 90s
+routes:
+  - name: orders
+    path: /api/v1/orders/
+    host: http://localhost:8000
+    timeouts:
+      request: 10s
+    retries:
+      attempts: 2
+      on_status: [502, 503]
+  - name: users
+    path: /api/v1/users/
+    targets:
+      - url: https://users-1.internal
+        weight: 2
+      - url: https://users-2.internal
+    tls:
+      ca_file: /etc/apigw/internal-ca.crt
+      server_name: users.internal
+    load_balancing:
+      strategy: weighted
+    health_check:
+      active:
+        path: /healthz
+        interval: 5s
+    middlewares: [auth]
+    rate_limit:
+      algorithm: sliding_log
+redis:
+  host: localhost
+  port: 6379
+security:
+  api_key_pepper: pepper
+tls:
+  cert_file: /etc/apigw/tls.crt
+  key_file: /etc/apigw/tls.key
+  min_version: "1.3"
+  cipher_suites: [TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256]
+  redirect_port: 8080
+`
+
+func TestLoadFile(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		content  string
+		wantErrs []string
+	}{
+		{
+			desc:    "Test valid config",
+			content: validConfig,
+		},
+		{
+			desc: "Test every problem is reported",
+			content: `
+app:
+  port: 70000
+routes:
+  - path: /api/
+    hots: http://localhost:8000
+  - path: /api/v1/orders/
+    host: localhost:8000
+    health_check:
+      active:
+        path: healthz
+        interval: -1s
+      passive:
+        unhealthy_threshold: -1
+  - path: /api/
+    host: http://localhost:8001
+redis:
+  port: redis
+`,
+			wantErrs: []string{
+				"has invalid keys: hots",
+				`app.port: "70000" is not a valid port`,
+				"redis.host: is required",
+				`redis.port: "redis" is not a valid port`,
+				"security.api_key_pepper: is required",
+				"routes[0].host: is required",
+				`routes[1].host: "localhost:8000" must be an http or https URL`,
+				`routes[1].health_check.active.path: "healthz" must start with /`,
+				"routes[1].health_check.active.interval: -1s is negative",
+				"routes[1].health_check.passive.unhealthy_threshold: -1 is negative",
+				`routes[1].path: "/api/v1/orders/" is shadowed by routes[0] path "/api/"`,
+				`routes[2].path: "/api/" is already used by routes[0]`,
+			},
+		},
+		{
+			desc: "Test duplicate names, relative paths and bad targets",
+			content: `
+routes:
+  - name: orders
+    path: api/v1/orders/
+    host: http://localhost:8000
+  - name: orders
+    path: /api/v2/orders/
+    host: http://localhost:8000
+  - path: /api/v1/users/
+    host: http://localhost:8001
+    targets:
+      - url: http://localhost:8002
+  - path: /api/v1/billing/
+    targets:
+      - url: localhost:8003
+      - url: http://localhost:8004
+        weight: -1
+    timeouts:
+      connect: -1s
+    tls:
+      cert_file: /etc/apigw/billing.crt
+    retries:
+      attempts: 2
+      on_status: [503, 1000]
+redis:
+  host: localhost
+`,
+			wantErrs: []string{
+				"routes[2]: host and targets are mutually exclusive",
+				`routes[3].targets[0].url: "localhost:8003" must be an http or https URL`,
+				"routes[3].targets[1].weight: -1 is negative",
+				"routes[3].timeouts.connect: -1s is negative",
+				"routes[3].tls: cert_file and key_file must be set together",
+				"routes[3].retries.on_status[1]: 1000 is not an HTTP status",
+				"redis.port: is required",
+				`routes[0].path: "api/v1/orders/" must start with /`,
+				`routes[1].name: "orders" is already used by routes[0]`,
+			},
+		},
+		{
+			desc: "Test invalid tls, access log, tracing and audit",
+			content: `
+redis:
+  host: localhost
+  port: 6379
+tls:
+  cert_file: /etc/apigw/tls.crt
+  min_version: "1.1"
+  cipher_suites: [TLS_RSA_WITH_RC4_128_SHA, TLS_AES_1]
+  redirect_port: http
+access_log:
+  sample_rate: 2
+tracing:
+  endpoint: otel-collector:4318
+  sample_ratio: 1.5
+audit:
+  file: /var/log/apigw/audit.log
+  max_backups: -1
+`,
+			wantErrs: []string{
+				"tls: cert_file and key_file must be set together",
+				`tls.min_version: unsupported TLS version "1.1"`,
+				`tls.cipher_suites: unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`,
+				`unknown or insecure cipher suite "TLS_AES_1"`,
+				`tls.redirect_port: "http" is not a valid port`,
+				"access_log.sample_rate: 2 is not between 0 and 1",
+				`tracing.endpoint: "otel-collector:4318" must be an http or https URL`,
+				"tracing.sample_ratio: 1.5 is not between 0 and 1",
+				"audit.max_backups: -1 is negative",
+			},
+		},
+		{
+			desc: "Test basic and mtls auth that reject every request",
+			content: `
+routes:
+  - path: /api/v1/orders/
+    host: http://localhost:8000
+    auth:
+      type: basic
+      htpasswd_file: /etc/apigw/htpasswd
+  - path: /api/v1/users/
+    host: http://localhost:8001
+    auth:
+      type: mtls
+      rate_limit: 60
+  - path: /api/v1/billing/
+    host: http://localhost:8002
+    middlewares: [auth]
+    auth:
+      type: basic
+      htpasswd_file: /etc/apigw/htpasswd
+  - path: /api/v1/public/
+    host: http://localhost:8003
+    middlewares: [auth, ratelimit]
+    auth:
+      type: keyless
+  - path: /api/v1/docs/
+    host: http://localhost:8004
+    auth:
+      type: keyless
+redis:
+  host: localhost
+  port: 6379
+`,
+			wantErrs: []string{
+				"routes[0].auth.rate_limit: must be positive for basic auth with the ratelimit middleware",
+				"routes[1].auth.type: mtls requires tls.client_ca_file",
+				"routes[3].auth.type: keyless routes cannot use the ratelimit middleware",
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "master.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tC.content), 0o600))
+
+			cfg, err := config.LoadFile(path)
+			if len(tC.wantErrs) == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, "9000", cfg.GetPort())
+				assert.Equal(t, 90*time.Second, cfg.App.WriteTimeout)
+				assert.Equal(t, 10*time.Second, cfg.Routes[0].Timeouts.Request)
+				assert.Equal(t, []int{502, 503}, cfg.Routes[0].Retries.OnStatus)
+				assert.Len(t, cfg.GetRoutes(), 2)
+				assert.Equal(t, "sliding_log", cfg.Routes[1].RateLimit.Algorithm)
+				assert.Equal(t, []config.Target{
+					{URL: "https://users-1.internal", Weight: 2},
+					{URL: "https://users-2.internal", Weight: 1},
+				}, cfg.Routes[1].GetTargets())
+				assert.Equal(t, "weighted", cfg.Routes[1].LoadBalancing.Strategy)
+				assert.Equal(t, config.UpstreamTLS{CAFile: "/etc/apigw/internal-ca.crt", ServerName: "users.internal"}, cfg.Routes[1].TLS)
+				assert.Equal(t, 5*time.Second, cfg.Routes[1].HealthCheck.Active.Interval)
+				assert.True(t, cfg.TLS.Enabled())
+				version, err := cfg.TLS.Version()
+				require.NoError(t, err)
+				assert.Equal(t, uint16(tls.VersionTLS13), version)
+				suites, err := cfg.TLS.CipherSuiteIDs()
+				require.NoError(t, err)
+				assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, suites)
+				return
+			}
+			require.Error(t, err)
+			assert.Nil(t, cfg)
+			for _, want := range tC.wantErrs {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestLoadFile_EnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+app:
+  port: 9000
+redis:
+  host: localhost
+`), 0o600))
+
+	t.Setenv("APIGW_APP_PORT", "9100")
+	t.Setenv("APIGW_REDIS_HOST", "redis")
+	t.Setenv("APIGW_REDIS_PORT", "6380")
+	t.Setenv("APIGW_REDIS_PASSWORD", "from-secret")
+	t.Setenv("APIGW_SECURITY_API_KEY_PEPPER", "pepper")
+
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "9100", cfg.GetPort())
+	assert.Equal(t, "redis", cfg.Redis.Host)
+	assert.Equal(t, "6380", cfg.Redis.Port)
+	assert.Equal(t, "from-secret", cfg.Redis.Password)
+	assert.Equal(t, "pepper", cfg.Security.APIKeyPepper)
+}
+
+func TestFile(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		appEnv   string
+		env      string
+		path     string
+		expected string
+	}{
+		{
+			desc:     "Test default environment",
+			expected: filepath.Join("config", "local", "master.yaml"),
+		},
+		{
+			desc:     "Test APP_ENV selects the environment",
+			appEnv:   "staging",
+			expected: filepath.Join("config", "staging", "master.yaml"),
+		},
+		{
+			desc:     "Test env takes precedence over APP_ENV",
+			appEnv:   "staging",
+			env:      "prod",
+			expected: filepath.Join("config", "prod", "master.yaml"),
+		},
+		{
+			desc:     "Test explicit path wins",
+			appEnv:   "staging",
+			env:      "prod",
+			path:     "/etc/apigw/master.yaml",
+			expected: "/etc/apigw/master.yaml",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Setenv(config.EnvVar, tC.appEnv)
+			config.SetEnv(tC.env)
+			config.SetPath(tC.path)
+			defer config.SetEnv("")
+			defer config.SetPath("")
+
+			assert.Equal(t, tC.expected, config.File())
+		})
+	}
+}
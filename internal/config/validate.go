@@ -0,0 +1,262 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate reports every problem that would stop the config from being
+// served
+func (c *Config) Validate() error {
+	var errs []error
+	if c.App.Port != "" {
+		if err := validatePort(c.App.Port); err != nil {
+			errs = append(errs, fmt.Errorf("app.port: %w", err))
+		}
+	}
+
+	errs = append(errs, negative("app", map[string]time.Duration{
+		"read_header_timeout": c.App.ReadHeaderTimeout,
+		"read_timeout":        c.App.ReadTimeout,
+		"write_timeout":       c.App.WriteTimeout,
+		"idle_timeout":        c.App.IdleTimeout,
+		"drain_period":        c.App.DrainPeriod,
+		"shutdown_timeout":    c.App.ShutdownTimeout,
+	})...)
+
+	errs = append(errs, c.TLS.validate()...)
+	if rate := c.AccessLog.SampleRate; rate < 0 || rate > 1 {
+		errs = append(errs, fmt.Errorf("access_log.sample_rate: %g is not between 0 and 1", rate))
+	}
+	if c.Tracing.Endpoint != "" {
+		if err := validateUpstream(c.Tracing.Endpoint); err != nil {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %w", err))
+		}
+	}
+	if ratio := c.Tracing.SampleRatio; ratio < 0 || ratio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio: %g is not between 0 and 1", ratio))
+	}
+	errs = append(errs, negative("audit", map[string]int{
+		"max_size_mb":  c.Audit.MaxSizeMB,
+		"max_backups":  c.Audit.MaxBackups,
+		"max_age_days": c.Audit.MaxAgeDays,
+	})...)
+
+	// An empty pepper would leave stored key hashes open to offline guessing
+	if c.Security.APIKeyPepper == "" {
+		errs = append(errs, errors.New("security.api_key_pepper: is required"))
+	}
+
+	if c.Redis.Host == "" {
+		errs = append(errs, errors.New("redis.host: is required"))
+	}
+	if c.Redis.Port == "" {
+		errs = append(errs, errors.New("redis.port: is required"))
+	} else if err := validatePort(c.Redis.Port); err != nil {
+		errs = append(errs, fmt.Errorf("redis.port: %w", err))
+	}
+	if c.Redis.DB < 0 {
+		errs = append(errs, fmt.Errorf("redis.db: %d is negative", c.Redis.DB))
+	}
+
+	names := map[string]int{}
+	paths := map[string]int{}
+	for i, route := range c.GetRoutes() {
+		field := fmt.Sprintf("routes[%d]", i)
+		if route.Path == "" {
+			errs = append(errs, fmt.Errorf("%s.path: is required", field))
+		} else if !strings.HasPrefix(route.Path, "/") {
+			errs = append(errs, fmt.Errorf("%s.path: %q must start with /", field, route.Path))
+		}
+		switch {
+		case len(route.Targets) == 0:
+			if err := validateUpstream(route.Host); err != nil {
+				errs = append(errs, fmt.Errorf("%s.host: %w", field, err))
+			}
+		case route.Host != "":
+			errs = append(errs, fmt.Errorf("%s: host and targets are mutually exclusive", field))
+		default:
+			for j, target := range route.Targets {
+				if err := validateUpstream(target.URL); err != nil {
+					errs = append(errs, fmt.Errorf("%s.targets[%d].url: %w", field, j, err))
+				}
+				if target.Weight < 0 {
+					errs = append(errs, fmt.Errorf("%s.targets[%d].weight: %d is negative", field, j, target.Weight))
+				}
+			}
+		}
+
+		errs = append(errs, validateHealthCheck(field+".health_check", route.HealthCheck)...)
+		errs = append(errs, validateCircuitBreaker(field+".circuit_breaker", route.CircuitBreaker)...)
+		errs = append(errs, c.validateAuth(field+".auth", route)...)
+		if (route.TLS.CertFile == "") != (route.TLS.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("%s.tls: cert_file and key_file must be set together", field))
+		}
+		errs = append(errs, negative(field, map[string]time.Duration{
+			"timeouts.connect":            route.Timeouts.Connect,
+			"timeouts.response_header":    route.Timeouts.ResponseHeader,
+			"timeouts.request":            route.Timeouts.Request,
+			"retries.backoff":             route.Retries.Backoff,
+			"transport.idle_conn_timeout": route.Transport.IdleConnTimeout,
+		})...)
+		errs = append(errs, negative(field, map[string]int{
+			"retries.attempts":                  route.Retries.Attempts,
+			"transport.max_idle_conns_per_host": route.Transport.MaxIdleConnsPerHost,
+		})...)
+		for j, status := range route.Retries.OnStatus {
+			if status < 100 || status > 599 {
+				errs = append(errs, fmt.Errorf("%s.retries.on_status[%d]: %d is not an HTTP status", field, j, status))
+			}
+		}
+
+		if route.Name != "" {
+			if j, ok := names[route.Name]; ok {
+				errs = append(errs, fmt.Errorf("%s.name: %q is already used by routes[%d]", field, route.Name, j))
+			} else {
+				names[route.Name] = i
+			}
+		}
+		if route.Path == "" {
+			continue
+		}
+		if j, ok := paths[route.Path]; ok {
+			errs = append(errs, fmt.Errorf("%s.path: %q is already used by routes[%d]", field, route.Path, j))
+			continue
+		}
+		// Routes match by prefix in order, so a route under an earlier
+		// route's path is never reached
+		for j, earlier := range c.Routes[:i] {
+			if earlier.Path != "" && earlier.Path != route.Path && strings.HasPrefix(route.Path, earlier.Path) {
+				errs = append(errs, fmt.Errorf("%s.path: %q is shadowed by routes[%d] path %q", field, route.Path, j, earlier.Path))
+			}
+		}
+		paths[route.Path] = i
+	}
+	return errors.Join(errs...)
+}
+
+func (t TLS) validate() []error {
+	var errs []error
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs = append(errs, errors.New("tls: cert_file and key_file must be set together"))
+	}
+	if !t.Enabled() && (t.ClientCAFile != "" || t.RedirectPort != "") {
+		errs = append(errs, errors.New("tls: client_ca_file and redirect_port require cert_file"))
+	}
+	if _, err := t.Version(); err != nil {
+		errs = append(errs, fmt.Errorf("tls.min_version: %w", err))
+	}
+	if _, err := t.CipherSuiteIDs(); err != nil {
+		errs = append(errs, fmt.Errorf("tls.cipher_suites: %w", err))
+	}
+	if t.RedirectPort != "" {
+		if err := validatePort(t.RedirectPort); err != nil {
+			errs = append(errs, fmt.Errorf("tls.redirect_port: %w", err))
+		}
+	}
+	return errs
+}
+
+// validateAuth reports auth policies of route that can never let a
+// request through
+func (c *Config) validateAuth(field string, route Route) []error {
+	chain := route.GetMiddlewares()
+	if !slices.Contains(chain, "auth") {
+		return nil
+	}
+	var errs []error
+	switch route.Auth.Type {
+	case "keyless":
+		// The limiter needs the token auth does not look up
+		if slices.Contains(chain, "ratelimit") {
+			errs = append(errs, fmt.Errorf("%s.type: keyless routes cannot use the ratelimit middleware", field))
+		}
+	case "basic", "mtls":
+		// Basic and mtls identities are limited by the route's rate_limit,
+		// without one the ratelimit middleware rejects every request
+		if slices.Contains(chain, "ratelimit") && route.Auth.RateLimit <= 0 {
+			errs = append(errs, fmt.Errorf("%s.rate_limit: must be positive for %s auth with the ratelimit middleware", field, route.Auth.Type))
+		}
+	}
+	if route.Auth.Type == "mtls" && c.TLS.ClientCAFile == "" {
+		errs = append(errs, fmt.Errorf("%s.type: mtls requires tls.client_ca_file", field))
+	}
+	return errs
+}
+
+func validateHealthCheck(field string, hc HealthCheck) []error {
+	var errs []error
+	if hc.Active.Path != "" && !strings.HasPrefix(hc.Active.Path, "/") {
+		errs = append(errs, fmt.Errorf("%s.active.path: %q must start with /", field, hc.Active.Path))
+	}
+	errs = append(errs, negative(field, map[string]time.Duration{
+		"active.interval":           hc.Active.Interval,
+		"active.timeout":            hc.Active.Timeout,
+		"passive.ejection_duration": hc.Passive.EjectionDuration,
+	})...)
+	errs = append(errs, negative(field, map[string]int{
+		"active.healthy_threshold":    hc.Active.HealthyThreshold,
+		"active.unhealthy_threshold":  hc.Active.UnhealthyThreshold,
+		"passive.unhealthy_threshold": hc.Passive.UnhealthyThreshold,
+	})...)
+	return errs
+}
+
+func validateCircuitBreaker(field string, cb CircuitBreaker) []error {
+	var errs []error
+	if cb.ErrorRate < 0 || cb.ErrorRate > 1 {
+		errs = append(errs, fmt.Errorf("%s.error_rate: %g is not between 0 and 1", field, cb.ErrorRate))
+	}
+	errs = append(errs, negative(field, map[string]time.Duration{
+		"latency_threshold": cb.LatencyThreshold,
+		"window":            cb.Window,
+		"open_duration":     cb.OpenDuration,
+	})...)
+	errs = append(errs, negative(field, map[string]int{
+		"min_requests":       cb.MinRequests,
+		"half_open_requests": cb.HalfOpenRequests,
+	})...)
+	return errs
+}
+
+// negative reports the settings of field below zero, sorted by name
+func negative[T int | time.Duration](field string, values map[string]T) []error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if values[name] < 0 {
+			errs = append(errs, fmt.Errorf("%s.%s: %v is negative", field, name, values[name]))
+		}
+	}
+	return errs
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%q is not a valid port", port)
+	}
+	return nil
+}
+
+func validateUpstream(host string) error {
+	if host == "" {
+		return errors.New("is required")
+	}
+	target, err := url.Parse(host)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL: %w", host, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("%q must be an http or https URL", host)
+	}
+	if target.Host == "" {
+		return fmt.Errorf("%q has no host", host)
+	}
+	return nil
+}
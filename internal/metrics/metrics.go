@@ -1,16 +1,24 @@
 package metrics
 
 import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Requests are labelled by the name of the route they matched rather than
+// their path, which would add a series per ID in the URL
 var (
 	HttpRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
 			Help: "Total HTTP requests received",
 		},
-		[]string{"method", "path"},
+		[]string{"route", "method", "status_class", "upstream"},
 	)
 
 	RequestDuration = prometheus.NewHistogramVec(
@@ -19,24 +27,122 @@ var (
 			Help:    "Histogram of request durations",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"path"},
+		[]string{"route", "method", "status_class"},
+	)
+
+	HttpRequestsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Requests currently being served",
+		},
+		[]string{"route"},
+	)
+
+	UpstreamRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "upstream_request_duration_seconds",
+			Help:    "Histogram of the durations of single attempts at an upstream target; status_class is error when no response was received",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"route", "upstream", "status_class"},
+	)
+
+	UpstreamRequestsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "upstream_requests_in_flight",
+			Help: "Requests currently sent to an upstream target",
+		},
+		[]string{"route", "upstream"},
 	)
 
-	RateLimitHits = prometheus.NewCounter(
+	// RateLimitHits is labelled with the API key hash only when
+	// config.Metrics.TokenLabel is set
+	RateLimitHits = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "rate_limit_hits_total",
-			Help: "Total requests blocked due to rate limiting",
+			Help: "Total requests rejected by the rate limiter, by reason: quota_exceeded or backend_error",
 		},
+		[]string{"route", "reason", "token"},
 	)
 
-	AuthFailures = prometheus.NewCounter(
+	AuthFailures = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "auth_failures_total",
-			Help: "Total number of failed token validations",
+			Help: "Total requests rejected by authentication, by reason, e.g. missing_key or expired",
+		},
+		[]string{"route", "reason"},
+	)
+
+	UpstreamTargetHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "upstream_target_healthy",
+			Help: "Whether an upstream target is in rotation (1) or removed by health checks (0)",
+		},
+		[]string{"route", "target"},
+	)
+
+	CircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "State of a route's circuit breaker: 0 closed, 1 half-open, 2 open",
+		},
+		[]string{"route"},
+	)
+
+	CircuitBreakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_transitions_total",
+			Help: "Total state changes of a route's circuit breaker",
 		},
+		[]string{"route", "from", "to"},
 	)
 )
 
+var initOnce sync.Once
+
+// Init registers the metrics with the default Prometheus registry. It is
+// safe to call more than once.
 func Init() {
-	prometheus.MustRegister(HttpRequestsTotal, RequestDuration, RateLimitHits, AuthFailures)
+	initOnce.Do(func() {
+		prometheus.MustRegister(
+			HttpRequestsTotal, RequestDuration, HttpRequestsInFlight,
+			UpstreamRequestDuration, UpstreamRequestsInFlight, RateLimitHits, AuthFailures,
+			UpstreamTargetHealthy, CircuitBreakerState, CircuitBreakerTransitions,
+		)
+	})
+}
+
+// StatusClass groups a status code, e.g. 2xx
+func StatusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// Method returns the request method as a label, folding nonstandard ones
+// clients can make up into OTHER
+func Method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+type routeKey struct{}
+
+// WithRoute records the name of the route r matched, for handlers wrapping
+// the router that run before it dispatches the request
+func WithRoute(r *http.Request, name string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, name))
+}
+
+// Route returns the name of the route r matched, "" when it matched none
+func Route(r *http.Request) string {
+	if name, ok := r.Context().Value(routeKey{}).(string); ok {
+		return name
+	}
+	if current := mux.CurrentRoute(r); current != nil {
+		return current.GetName()
+	}
+	return ""
 }
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/redis/go-redis/v9"
+)
+
+// APIKeyAuthenticator looks up opaque API keys in the token store. Bearer
+// JWTs are validated instead when a JWT validator is set.
+type APIKeyAuthenticator struct {
+	TokenService tokenservice.Service
+	JWT          *JWTValidator
+	// Header carrying the key, Authorization by default
+	Header string
+	// QueryParam carrying the key when the header is absent
+	QueryParam string
+}
+
+// KeyFromRequest reads the API key from header, Authorization by default,
+// dropping a Bearer prefix, or from queryParam when the header is absent
+func KeyFromRequest(r *http.Request, header, queryParam string) string {
+	if header == "" {
+		header = "Authorization"
+	}
+	apiKey := strings.TrimPrefix(r.Header.Get(header), "Bearer ")
+	if apiKey == "" && queryParam != "" {
+		apiKey = r.URL.Query().Get(queryParam)
+	}
+	return apiKey
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (models.TokenData, error) {
+	apiKey := KeyFromRequest(r, a.Header, a.QueryParam)
+	// Check if the API key is present
+	if apiKey == "" {
+		return models.TokenData{}, ErrMissingKey
+	}
+
+	if a.JWT != nil && IsJWT(apiKey) {
+		token, err := a.JWT.Validate(apiKey)
+		if err != nil {
+			return models.TokenData{}, jwtError(err)
+		}
+		return token, nil
+	}
+
+	token, err := a.TokenService.GetToken(r.Context(), apiKey)
+	if err != nil {
+		if err == redis.Nil {
+			return models.TokenData{}, ErrInvalidKey
+		}
+		return models.TokenData{}, err
+	}
+	if token.IsExpired() {
+		return models.TokenData{}, ErrExpiredKey
+	}
+	// Check if the token is valid
+	if !token.IsValid() {
+		return models.TokenData{}, ErrInvalidKey
+	}
+	return token, nil
+}
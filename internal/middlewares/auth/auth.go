@@ -3,14 +3,23 @@ package auth
 import (
 	"context"
 	"net/http"
+	"strings"
 
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
 	"github.com/arjunksofficial/tyk-task/internal/token/models"
 	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
-	"github.com/redis/go-redis/v9"
+	"github.com/arjunksofficial/tyk-task/internal/tracing"
 )
 
+// AuthMiddleware dispatches authentication to the route's Authenticator
 type AuthMiddleware struct {
-	TokenService tokenservice.Service
+	// Authenticator verifies the request; when nil API keys are looked up
+	// in TokenService and bearer JWTs validated with JWT
+	Authenticator Authenticator
+	TokenService  tokenservice.Service
+	JWT           *JWTValidator
+	// Route names the route in metrics and the audit log
+	Route string
 }
 
 // NewAuthMiddleware creates a new instance of AuthMiddleware
@@ -20,39 +29,49 @@ func NewAuthMiddleware() *AuthMiddleware {
 	}
 }
 
-// AuthMiddleware is a middleware function that checks if the request has a valid API key
+// AuthMiddleware is a middleware function that checks if the request has valid credentials
 func (a *AuthMiddleware) AuthMiddleware(next http.Handler) http.Handler {
+	authenticator := a.Authenticator
+	if authenticator == nil {
+		authenticator = &APIKeyAuthenticator{TokenService: a.TokenService, JWT: a.JWT}
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := r.Header.Get("Authorization")
-		// Check if the API key is present
-		if apiKey == "" {
-			http.Error(w, "Unauthorized: API key is missing", http.StatusUnauthorized)
-			return
-		}
-		// remove Bearer prefix if present
-		if len(apiKey) > 7 && apiKey[:7] == "Bearer " {
-			apiKey = apiKey[7:]
-		}
-		token, err := a.TokenService.GetToken(r.Context(), apiKey)
+		ctx, span := tracing.Start(r.Context(), "auth.authenticate")
+		token, err := authenticator.Authenticate(r.WithContext(ctx))
+		tracing.End(span, err)
 		if err != nil {
-			if err == redis.Nil {
-				http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
-			} else {
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			}
+			writeError(w, r, a.Route, err)
 			return
 		}
-		// Check if the token is valid
-		if !token.IsValid() {
-			http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
+		logging.SetAPIKey(r.Context(), token.KeyHash)
+		// Checked here rather than by the limiter, so routes without the
+		// ratelimit middleware are authorised too
+		if !AllowsRoute(token, r.URL.Path) {
+			writeError(w, r, a.Route, ErrForbiddenRoute)
 			return
 		}
 		// Set the token in the request context for further processing
-		ctx := r.Context()
+		ctx = r.Context()
 		ctx = context.WithValue(ctx, models.TokenContextKey, token)
 		r = r.WithContext(ctx)
 
-		// If the API key is valid, proceed to the next handler
+		// If the credentials are valid, proceed to the next handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// AllowsRoute reports whether the token's allowed routes cover path:
+// "/api/v1/users/*" matches "/api/v1/users/123", other patterns match
+// exactly
+func AllowsRoute(token models.TokenData, path string) bool {
+	for _, pattern := range token.AllowedRoutes {
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/arjunksofficial/tyk-task/internal/audit"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
+)
+
+// Supported auth schemes for config.Auth.Type
+const (
+	Keyless = "keyless"
+	APIKey  = "api_key"
+	Basic   = "basic"
+	JWT     = "jwt"
+	MTLS    = "mtls"
+)
+
+// Authenticator verifies the credentials of a request and returns the token
+// data rate limiting and later middlewares work with
+type Authenticator interface {
+	Authenticate(r *http.Request) (models.TokenData, error)
+}
+
+// Error is an authentication failure reported to the client as is. Any
+// other error returned by an Authenticator is an internal error.
+type Error struct {
+	Status  int
+	Message string
+	// Reason labels the failure in metrics and the audit log, one of the
+	// audit.Reason constants
+	Reason string
+	// Challenge is sent as WWW-Authenticate when set
+	Challenge string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrMissingKey         = &Error{Status: http.StatusUnauthorized, Message: "Unauthorized: API key is missing", Reason: audit.ReasonMissingKey}
+	ErrInvalidKey         = &Error{Status: http.StatusUnauthorized, Message: "Unauthorized: Invalid API key", Reason: audit.ReasonUnknownKey}
+	ErrExpiredKey         = &Error{Status: http.StatusUnauthorized, Message: "Unauthorized: Invalid API key", Reason: audit.ReasonExpired}
+	ErrMissingToken       = &Error{Status: http.StatusUnauthorized, Message: "Unauthorized: Token is missing", Reason: audit.ReasonMissingKey}
+	ErrInvalidToken       = &Error{Status: http.StatusUnauthorized, Message: "Unauthorized: Invalid token", Reason: audit.ReasonInvalidCredentials}
+	ErrExpiredToken       = &Error{Status: http.StatusUnauthorized, Message: "Unauthorized: Invalid token", Reason: audit.ReasonExpired}
+	ErrMissingCertificate = &Error{Status: http.StatusUnauthorized, Message: "Unauthorized: Client certificate is missing", Reason: audit.ReasonMissingKey}
+	ErrForbiddenSubject   = &Error{Status: http.StatusForbidden, Message: "Forbidden: Client certificate not allowed", Reason: audit.ReasonRouteForbidden}
+	ErrForbiddenRoute     = &Error{Status: http.StatusForbidden, Message: "Route not allowed for this token", Reason: audit.ReasonRouteForbidden}
+)
+
+// writeError records an authentication failure on route and writes its
+// response
+func writeError(w http.ResponseWriter, r *http.Request, route string, err error) {
+	var authErr *Error
+	if !errors.As(err, &authErr) {
+		authErr = &Error{Status: http.StatusInternalServerError, Message: "Internal Server Error", Reason: audit.ReasonBackendError}
+	}
+	metrics.AuthFailures.WithLabelValues(route, authErr.Reason).Inc()
+	audit.Record(r, audit.Event{Route: route, Reason: authErr.Reason, Status: authErr.Status})
+	if authErr.Challenge != "" {
+		w.Header().Set("WWW-Authenticate", authErr.Challenge)
+	}
+	requestid.Error(w, r, authErr.Message, authErr.Status)
+}
+
+// NewAuthenticator builds the authenticator selected by the route's auth
+// policy. Keyless routes get a nil Authenticator. tokens is only called for
+// api_key routes, which look keys up through it.
+func NewAuthenticator(route config.Route, jwtCfg config.JWT, tokens func() tokenservice.Service) (Authenticator, error) {
+	policy := route.Auth
+	switch policy.Type {
+	case Keyless:
+		return nil, nil
+	case "", APIKey:
+		jwtValidator, err := NewJWTValidator(jwtCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &APIKeyAuthenticator{
+			TokenService: tokens(),
+			JWT:          jwtValidator,
+			Header:       policy.Header,
+			QueryParam:   policy.QueryParam,
+		}, nil
+	case JWT:
+		jwtValidator, err := NewJWTValidator(jwtCfg)
+		if err != nil {
+			return nil, err
+		}
+		if jwtValidator == nil {
+			return nil, errors.New("jwt auth requires jwt.hmac_secret or jwt.jwks_file")
+		}
+		return &JWTAuthenticator{Validator: jwtValidator}, nil
+	case Basic:
+		users, err := LoadHtpasswd(policy.HtpasswdFile)
+		if err != nil {
+			return nil, err
+		}
+		return &BasicAuthenticator{Users: users, Token: identityToken(route)}, nil
+	case MTLS:
+		return &MTLSAuthenticator{AllowedSubjects: policy.AllowedSubjects, Token: identityToken(route)}, nil
+	}
+	return nil, fmt.Errorf("unknown auth type %q", policy.Type)
+}
+
+// identityToken is the token template for identities that carry no token
+// data of their own, such as basic auth users and client certificates. It
+// allows the route and applies the route's auth rate limit.
+func identityToken(route config.Route) models.TokenData {
+	return models.TokenData{
+		RateLimit:     route.Auth.RateLimit,
+		AllowedRoutes: []string{strings.TrimSuffix(route.Path, "/") + "/*"},
+	}
+}
@@ -0,0 +1,257 @@
+package auth_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/audit"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/auth"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAPIKeyAuthenticator_Authenticate(t *testing.T) {
+	validToken := models.TokenData{
+		KeyHash:       "hash",
+		RateLimit:     10,
+		ExpiresAt:     time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		AllowedRoutes: []string{"/api/v1/resource"},
+	}
+
+	testCases := []struct {
+		desc          string
+		header        string
+		queryParam    string
+		setup         func(r *http.Request)
+		mockTokenSvc  func(*services.MockService)
+		expectedErr   error
+		expectedToken models.TokenData
+	}{
+		{
+			desc:   "Test key in custom header",
+			header: "X-API-Key",
+			setup:  func(r *http.Request) { r.Header.Set("X-API-Key", "valid_api_key") },
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "valid_api_key").Return(validToken, nil)
+			},
+			expectedToken: validToken,
+		},
+		{
+			desc:       "Test key in query parameter",
+			queryParam: "api_key",
+			setup:      func(r *http.Request) { r.URL.RawQuery = "api_key=valid_api_key" },
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "valid_api_key").Return(validToken, nil)
+			},
+			expectedToken: validToken,
+		},
+		{
+			desc:         "Test query parameter ignored when not configured",
+			setup:        func(r *http.Request) { r.URL.RawQuery = "api_key=valid_api_key" },
+			mockTokenSvc: func(*services.MockService) {},
+			expectedErr:  auth.ErrMissingKey,
+		},
+		{
+			desc:  "Test expired key",
+			setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer expired_api_key") },
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "expired_api_key").Return(models.TokenData{
+					KeyHash:   "hash",
+					ExpiresAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+				}, nil)
+			},
+			expectedErr: auth.ErrExpiredKey,
+		},
+		{
+			desc:  "Test backend error is internal",
+			setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer valid_api_key") },
+			mockTokenSvc: func(m *services.MockService) {
+				m.On("GetToken", mock.Anything, "valid_api_key").Return(models.TokenData{}, errors.New("some error"))
+			},
+			expectedErr: errors.New("some error"),
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mockTokenSvc := services.NewMockService(t)
+			tC.mockTokenSvc(mockTokenSvc)
+			a := auth.APIKeyAuthenticator{TokenService: mockTokenSvc, Header: tC.header, QueryParam: tC.queryParam}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+			tC.setup(req)
+
+			token, err := a.Authenticate(req)
+
+			assert.Equal(t, tC.expectedErr, err)
+			assert.Equal(t, tC.expectedToken, token)
+		})
+	}
+}
+
+func TestBasicAuthenticator_Authenticate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	assert.NoError(t, os.WriteFile(path, []byte("# partners\nalice:"+string(hash)+"\n"), 0o600))
+
+	authenticator, err := auth.NewAuthenticator(config.Route{
+		Path: "/api/v1/docs/",
+		Auth: config.Auth{Type: auth.Basic, HtpasswdFile: path, RateLimit: 30},
+	}, config.JWT{}, nil)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc          string
+		user          string
+		password      string
+		expectedErr   error
+		expectedToken models.TokenData
+	}{
+		{
+			desc:     "Test valid credentials",
+			user:     "alice",
+			password: "s3cret",
+			expectedToken: models.TokenData{
+				KeyHash:       "basic:alice",
+				RateLimit:     30,
+				AllowedRoutes: []string{"/api/v1/docs/*"},
+			},
+		},
+		{desc: "Test wrong password", user: "alice", password: "wrong", expectedErr: auth.ErrInvalidCredentials},
+		{desc: "Test unknown user", user: "bob", password: "s3cret", expectedErr: auth.ErrInvalidCredentials},
+		{desc: "Test missing credentials", expectedErr: auth.ErrInvalidCredentials},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/docs/index", nil)
+			if tC.user != "" {
+				req.SetBasicAuth(tC.user, tC.password)
+			}
+
+			token, err := authenticator.Authenticate(req)
+
+			assert.Equal(t, tC.expectedErr, err)
+			assert.Equal(t, tC.expectedToken, token)
+		})
+	}
+}
+
+func TestMTLSAuthenticator_Authenticate(t *testing.T) {
+	verified := func(cn string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	a := auth.MTLSAuthenticator{
+		AllowedSubjects: []string{"partner-a"},
+		Token:           models.TokenData{RateLimit: 60},
+	}
+
+	testCases := []struct {
+		desc          string
+		tls           *tls.ConnectionState
+		expectedErr   error
+		expectedToken models.TokenData
+	}{
+		{
+			desc:          "Test allowed certificate",
+			tls:           verified("partner-a"),
+			expectedToken: models.TokenData{KeyHash: "mtls:partner-a", RateLimit: 60},
+		},
+		{desc: "Test certificate not allowed", tls: verified("partner-b"), expectedErr: auth.ErrForbiddenSubject},
+		{desc: "Test plain HTTP", expectedErr: auth.ErrMissingCertificate},
+		{desc: "Test TLS without client certificate", tls: &tls.ConnectionState{}, expectedErr: auth.ErrMissingCertificate},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+			req.TLS = tC.tls
+
+			token, err := a.Authenticate(req)
+
+			assert.Equal(t, tC.expectedErr, err)
+			assert.Equal(t, tC.expectedToken, token)
+		})
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		auth        config.Auth
+		jwt         config.JWT
+		expectedNil bool
+		expectedErr string
+	}{
+		{desc: "Test keyless route has no authenticator", auth: config.Auth{Type: auth.Keyless}, expectedNil: true},
+		{desc: "Test api key by default", auth: config.Auth{}},
+		{desc: "Test jwt requires a key", auth: config.Auth{Type: auth.JWT}, expectedErr: "jwt auth requires jwt.hmac_secret or jwt.jwks_file"},
+		{desc: "Test jwt with shared secret", auth: config.Auth{Type: auth.JWT}, jwt: config.JWT{HMACSecret: "secret"}},
+		{desc: "Test basic requires htpasswd file", auth: config.Auth{Type: auth.Basic}, expectedErr: "basic auth requires auth.htpasswd_file"},
+		{desc: "Test mtls", auth: config.Auth{Type: auth.MTLS}},
+		{desc: "Test unknown type", auth: config.Auth{Type: "oauth"}, expectedErr: `unknown auth type "oauth"`},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			tokens := func() services.Service { return services.NewMockService(t) }
+			authenticator, err := auth.NewAuthenticator(config.Route{Path: "/api/v1/resource/", Auth: tC.auth}, tC.jwt, tokens)
+
+			if tC.expectedErr != "" {
+				assert.EqualError(t, err, tC.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expectedNil, authenticator == nil)
+		})
+	}
+}
+
+func TestAuthMiddleware_WritesChallenge(t *testing.T) {
+	a := auth.AuthMiddleware{Authenticator: &auth.BasicAuthenticator{}}
+	rr := httptest.NewRecorder()
+
+	a.AuthMiddleware(http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, `Basic realm="apigw", charset="UTF-8"`, rr.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "Unauthorized: Invalid credentials\n", rr.Body.String())
+}
+
+func TestAuthMiddleware_RecordsFailures(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		authenticator auth.Authenticator
+		reason        string
+	}{
+		{desc: "Test missing key", authenticator: &auth.APIKeyAuthenticator{}, reason: audit.ReasonMissingKey},
+		{desc: "Test invalid credentials", authenticator: &auth.BasicAuthenticator{}, reason: audit.ReasonInvalidCredentials},
+		{desc: "Test missing certificate", authenticator: &auth.MTLSAuthenticator{}, reason: audit.ReasonMissingKey},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			route := "auth-" + tC.reason
+			before := testutil.ToFloat64(metrics.AuthFailures.WithLabelValues(route, tC.reason))
+			a := auth.AuthMiddleware{Authenticator: tC.authenticator, Route: route}
+
+			a.AuthMiddleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, before+1, testutil.ToFloat64(metrics.AuthFailures.WithLabelValues(route, tC.reason)))
+		})
+	}
+}
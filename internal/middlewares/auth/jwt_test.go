@@ -0,0 +1,297 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/auth"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// writeJWKS writes the public halves of the keys to a JWKS file
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+		},
+	}
+	data, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestJWTValidator_Validate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	validator, err := auth.NewJWTValidator(config.JWT{
+		HMACSecret: "shared-secret",
+		JWKSFile:   writeJWKS(t, rsaKey, ecKey),
+		Issuer:     "https://issuer.internal",
+		Audience:   "apigw",
+	})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	claims := func(mutate func(*auth.Claims)) auth.Claims {
+		c := auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "orders-service",
+				Issuer:    "https://issuer.internal",
+				Audience:  jwt.ClaimStrings{"apigw"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+			RateLimit:     20,
+			AllowedRoutes: []string{"/api/v1/users/*"},
+		}
+		if mutate != nil {
+			mutate(&c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, c auth.Claims) string {
+		token := jwt.NewWithClaims(method, c)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return signed
+	}
+
+	testCases := []struct {
+		desc        string
+		token       string
+		expectedErr bool
+	}{
+		{
+			desc:  "Test HS256 with shared secret",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(nil)),
+		},
+		{
+			desc:  "Test RS256 with JWKS key",
+			token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(nil)),
+		},
+		{
+			desc:  "Test ES256 with JWKS key",
+			token: sign(jwt.SigningMethodES256, "ec-1", ecKey, claims(nil)),
+		},
+		{
+			desc:        "Test HS256 with wrong secret",
+			token:       sign(jwt.SigningMethodHS256, "", []byte("wrong-secret"), claims(nil)),
+			expectedErr: true,
+		},
+		{
+			desc:        "Test RS256 signed by unknown key",
+			token:       sign(jwt.SigningMethodRS256, "rsa-1", otherRSAKey, claims(nil)),
+			expectedErr: true,
+		},
+		{
+			desc:        "Test unknown kid",
+			token:       sign(jwt.SigningMethodRS256, "rsa-2", rsaKey, claims(nil)),
+			expectedErr: true,
+		},
+		{
+			desc:        "Test unsupported algorithm",
+			token:       sign(jwt.SigningMethodHS512, "", []byte("shared-secret"), claims(nil)),
+			expectedErr: true,
+		},
+		{
+			desc: "Test expired token",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(func(c *auth.Claims) {
+				c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+			})),
+			expectedErr: true,
+		},
+		{
+			desc: "Test token not valid yet",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(func(c *auth.Claims) {
+				c.NotBefore = jwt.NewNumericDate(now.Add(time.Hour))
+			})),
+			expectedErr: true,
+		},
+		{
+			desc: "Test wrong issuer",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(func(c *auth.Claims) {
+				c.Issuer = "https://evil.example"
+			})),
+			expectedErr: true,
+		},
+		{
+			desc: "Test wrong audience",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(func(c *auth.Claims) {
+				c.Audience = jwt.ClaimStrings{"billing"}
+			})),
+			expectedErr: true,
+		},
+		{
+			desc: "Test missing subject",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(func(c *auth.Claims) {
+				c.Subject = ""
+			})),
+			expectedErr: true,
+		},
+		{
+			desc: "Test missing expiry",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(func(c *auth.Claims) {
+				c.ExpiresAt = nil
+			})),
+			expectedErr: true,
+		},
+		{
+			desc: "Test missing rate limit",
+			token: sign(jwt.SigningMethodHS256, "", []byte("shared-secret"), claims(func(c *auth.Claims) {
+				c.RateLimit = 0
+			})),
+			expectedErr: true,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			token, err := validator.Validate(tC.token)
+
+			if tC.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, models.TokenData{
+				KeyHash:       "jwt:orders-service",
+				RateLimit:     20,
+				AllowedRoutes: []string{"/api/v1/users/*"},
+				ExpiresAt:     now.Add(time.Hour).UTC().Format(time.RFC3339),
+			}, token)
+		})
+	}
+}
+
+func TestAuthMiddleware_JWT(t *testing.T) {
+	validator, err := auth.NewJWTValidator(config.JWT{HMACSecret: "shared-secret"})
+	assert.NoError(t, err)
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "orders-service",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		RateLimit:     20,
+		AllowedRoutes: []string{"/api/v1/resource"},
+	}).SignedString([]byte("shared-secret"))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc           string
+		token          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			desc:           "Test valid JWT skips the token store",
+			token:          signed,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "jwt:orders-service",
+		},
+		{
+			desc:           "Test tampered JWT",
+			token:          signed + "x",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized: Invalid token\n",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			authM := auth.AuthMiddleware{
+				TokenService: services.NewMockService(t),
+				JWT:          validator,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+			req.Header.Set("Authorization", "Bearer "+tC.token)
+			rr := httptest.NewRecorder()
+
+			authM.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token := r.Context().Value(models.TokenContextKey).(models.TokenData)
+				w.Write([]byte(token.KeyHash))
+			})).ServeHTTP(rr, req)
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+			assert.Equal(t, tC.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestJWTAuthenticator_Authenticate(t *testing.T) {
+	validator, err := auth.NewJWTValidator(config.JWT{HMACSecret: "shared-secret"})
+	assert.NoError(t, err)
+	sign := func(expiresAt time.Time) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "orders-service",
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			RateLimit: 20,
+		}).SignedString([]byte("shared-secret"))
+		assert.NoError(t, err)
+		return signed
+	}
+	valid := sign(time.Now().Add(time.Hour))
+	expired := sign(time.Now().Add(-time.Minute))
+
+	testCases := []struct {
+		desc          string
+		authenticator auth.Authenticator
+		token         string
+		expectedErr   error
+	}{
+		{desc: "Test valid JWT", authenticator: &auth.JWTAuthenticator{Validator: validator}, token: valid},
+		{desc: "Test missing JWT", authenticator: &auth.JWTAuthenticator{Validator: validator}, expectedErr: auth.ErrMissingToken},
+		{desc: "Test expired JWT", authenticator: &auth.JWTAuthenticator{Validator: validator}, token: expired, expectedErr: auth.ErrExpiredToken},
+		{desc: "Test tampered JWT", authenticator: &auth.JWTAuthenticator{Validator: validator}, token: valid + "x", expectedErr: auth.ErrInvalidToken},
+		{desc: "Test expired JWT as API key", authenticator: &auth.APIKeyAuthenticator{JWT: validator}, token: expired, expectedErr: auth.ErrExpiredToken},
+		{desc: "Test tampered JWT as API key", authenticator: &auth.APIKeyAuthenticator{JWT: validator}, token: valid + "x", expectedErr: auth.ErrInvalidToken},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+			if tC.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tC.token)
+			}
+
+			token, err := tC.authenticator.Authenticate(req)
+
+			if tC.expectedErr != nil {
+				assert.Equal(t, tC.expectedErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "jwt:orders-service", token.KeyHash)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+)
+
+// MTLSAuthenticator identifies clients by the certificate they presented
+// during the TLS handshake. The listener must be configured to verify client
+// certificates against a CA, only verified chains are accepted here.
+type MTLSAuthenticator struct {
+	// AllowedSubjects restricts the accepted certificate common names; any
+	// verified certificate is accepted when empty
+	AllowedSubjects []string
+	// Token is the template for authenticated clients
+	Token models.TokenData
+}
+
+func (a *MTLSAuthenticator) Authenticate(r *http.Request) (models.TokenData, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return models.TokenData{}, ErrMissingCertificate
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if len(a.AllowedSubjects) > 0 && !slices.Contains(a.AllowedSubjects, subject) {
+		return models.TokenData{}, ErrForbiddenSubject
+	}
+	token := a.Token
+	token.KeyHash = "mtls:" + subject
+	return token, nil
+}
@@ -1,56 +1,194 @@
 package logging
 
 import (
-	"bytes"
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"slices"
+	"sync/atomic"
 	"time"
 
+	"github.com/arjunksofficial/tyk-task/internal/config"
 	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/arjunksofficial/tyk-task/internal/tracing"
 )
 
-// A wrapper to capture response status
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-	body       *bytes.Buffer
+// Access log fields, which config.AccessLog.Redact refers to
+const (
+	FieldRequestID = "request_id"
+	FieldTraceID   = "trace_id"
+	FieldClientIP  = "client_ip"
+	FieldMethod    = "method"
+	FieldPath      = "path"
+	FieldRoute     = "route"
+	FieldUpstream  = "upstream"
+	FieldStatus    = "status"
+	FieldBytesIn   = "bytes_in"
+	FieldBytesOut  = "bytes_out"
+	FieldLatency   = "latency_ms"
+	FieldAPIKey    = "api_key"
+)
+
+var fields = []string{
+	FieldRequestID, FieldTraceID, FieldClientIP, FieldMethod, FieldPath, FieldRoute, FieldUpstream,
+	FieldStatus, FieldBytesIn, FieldBytesOut, FieldLatency, FieldAPIKey,
+}
+
+// Redacted replaces the value of redacted fields
+const Redacted = "[REDACTED]"
+
+type entryKey struct{}
+
+// entry collects what handlers further down the chain learn about the
+// request, which the access log cannot see from its own request
+type entry struct {
+	upstream string
+	apiKey   string
 }
 
-func newResponseWriter(w http.ResponseWriter) *responseWriter {
-	return &responseWriter{w, http.StatusOK, &bytes.Buffer{}}
+// SetUpstream records the upstream target serving the request. With
+// retries the last target is logged.
+func SetUpstream(ctx context.Context, target string) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.upstream = target
+	}
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+// SetAPIKey records the hash of the API key the request authenticated with
+func SetAPIKey(ctx context.Context, keyHash string) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.apiKey = keyHash
+	}
 }
 
-func (rw *responseWriter) Write(b []byte) (int, error) {
-	rw.body.Write(b) // capture response body
-	return rw.ResponseWriter.Write(b)
+// AccessLogger writes a JSON object per request
+type AccessLogger struct {
+	logger     *slog.Logger
+	sampleRate float64
 }
 
-func LoggingMiddleware(next http.Handler) http.Handler {
+// NewAccessLogger creates an access logger writing to out
+func NewAccessLogger(cfg config.AccessLog, out io.Writer) (*AccessLogger, error) {
+	for _, field := range cfg.Redact {
+		if !slices.Contains(fields, field) {
+			return nil, fmt.Errorf("access_log.redact: unknown field %q", field)
+		}
+	}
+	sampleRate := cfg.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && slices.Contains(cfg.Redact, a.Key) {
+				return slog.String(a.Key, Redacted)
+			}
+			return a
+		},
+	})
+	return &AccessLogger{logger: slog.New(handler), sampleRate: sampleRate}, nil
+}
+
+// LoggingMiddleware logs the requests served by next
+func (l *AccessLogger) LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		rw := newResponseWriter(w)
+		e := &entry{}
+		r = r.WithContext(context.WithValue(r.Context(), entryKey{}, e))
+		body := &countingReader{ReadCloser: r.Body}
+		// NoBody is left alone, the proxy only retries requests without a
+		// body
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		rw := NewResponseWriter(w)
+		route := metrics.Route(r)
+		inFlight := metrics.HttpRequestsInFlight.WithLabelValues(route)
+		inFlight.Inc()
+		// the reverse proxy panics when the client aborts a streamed response
+		defer inFlight.Dec()
 
-		// Serve request
 		next.ServeHTTP(rw, r)
 
-		// Log response
 		duration := time.Since(start)
-		// log as json format in single line
-		log.Printf("{\"method\":\"%s\", \"path\":\"%s\", \"status\":%d, \"duration\":\"%s\"}",
-			r.Method,
-			r.URL.Path,
-			rw.statusCode,
-			duration,
+		method, class := metrics.Method(r.Method), metrics.StatusClass(rw.Status)
+		metrics.HttpRequestsTotal.WithLabelValues(route, method, class, e.upstream).Inc()
+		metrics.RequestDuration.WithLabelValues(route, method, class).Observe(duration.Seconds())
+
+		if rw.Status < http.StatusBadRequest && l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+			return
+		}
+		l.logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String(FieldRequestID, requestid.FromContext(r.Context())),
+			slog.String(FieldTraceID, tracing.TraceID(r.Context())),
+			slog.String(FieldClientIP, ClientIP(r)),
+			slog.String(FieldMethod, r.Method),
+			slog.String(FieldPath, r.URL.Path),
+			slog.String(FieldRoute, route),
+			slog.String(FieldUpstream, e.upstream),
+			slog.Int(FieldStatus, rw.Status),
+			slog.Int64(FieldBytesIn, body.n.Load()),
+			slog.Int64(FieldBytesOut, rw.Size),
+			slog.Float64(FieldLatency, float64(duration.Microseconds())/1000),
+			slog.String(FieldAPIKey, e.apiKey),
 		)
-		defer func() {
-			duration := time.Since(start).Seconds()
-			metrics.HttpRequestsTotal.WithLabelValues(r.Method, r.URL.Path).Inc()
-			metrics.RequestDuration.WithLabelValues(r.URL.Path).Observe(duration)
-		}()
 	})
 }
+
+// ClientIP is the address the request came from. X-Forwarded-For is not
+// trusted, any client can set it.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ResponseWriter records the status and size of the response written
+// through it
+type ResponseWriter struct {
+	http.ResponseWriter
+	Status int
+	Size   int64
+}
+
+// NewResponseWriter wraps w, reporting a 200 until another status is written
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (rw *ResponseWriter) WriteHeader(code int) {
+	rw.Status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *ResponseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.Size += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g.
+// to flush streamed responses
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// countingReader records the size of the request body read. The transport
+// may read it from its own goroutine.
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,113 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve sends a request through a router logging to the returned buffer
+func serve(t *testing.T, cfg config.AccessLog, status int, r *http.Request) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	accessLog, err := logging.NewAccessLogger(cfg, &out)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(requestid.New(""), accessLog.LoggingMiddleware)
+	router.PathPrefix("/api/v1/orders/").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		logging.SetAPIKey(r.Context(), "5e884898da28")
+		logging.SetUpstream(r.Context(), "http://orders-1:8000")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":42}`))
+	})).Name("orders")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	return &out
+}
+
+func TestAccessLogger(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/orders/42", strings.NewReader(`{"qty":1}`))
+	r.RemoteAddr = "203.0.113.7:51234"
+	r.Header.Set("X-Request-ID", "req-1")
+	out := serve(t, config.AccessLog{}, http.StatusCreated, r)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "request", line["msg"])
+	assert.NotEmpty(t, line["time"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "203.0.113.7", line["client_ip"])
+	assert.Equal(t, "POST", line["method"])
+	assert.Equal(t, "/api/v1/orders/42", line["path"])
+	assert.Equal(t, "orders", line["route"])
+	assert.Equal(t, "http://orders-1:8000", line["upstream"])
+	assert.Equal(t, 201.0, line["status"])
+	assert.Equal(t, 9.0, line["bytes_in"])
+	assert.Equal(t, 9.0, line["bytes_out"])
+	assert.Contains(t, line, "latency_ms")
+	assert.Equal(t, "5e884898da28", line["api_key"])
+}
+
+func TestAccessLogger_Redact(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/42", nil)
+	out := serve(t, config.AccessLog{Redact: []string{logging.FieldClientIP, logging.FieldAPIKey}}, http.StatusOK, r)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, logging.Redacted, line["client_ip"])
+	assert.Equal(t, logging.Redacted, line["api_key"])
+	assert.Equal(t, "GET", line["method"])
+
+	_, err := logging.NewAccessLogger(config.AccessLog{Redact: []string{"password"}}, io.Discard)
+	assert.ErrorContains(t, err, `unknown field "password"`)
+}
+
+func TestAccessLogger_Sampling(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		rate   float64
+		status int
+		logged bool
+	}{
+		{desc: "Test every request logged by default", status: http.StatusOK, logged: true},
+		{desc: "Test success sampled out", rate: 1e-9, status: http.StatusOK},
+		{desc: "Test client error always logged", rate: 1e-9, status: http.StatusTooManyRequests, logged: true},
+		{desc: "Test server error always logged", rate: 1e-9, status: http.StatusBadGateway, logged: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/42", nil)
+			out := serve(t, config.AccessLog{SampleRate: tC.rate}, tC.status, r)
+			assert.Equal(t, tC.logged, out.Len() > 0)
+		})
+	}
+}
+
+func TestAccessLogger_ClientAborted(t *testing.T) {
+	accessLog, err := logging.NewAccessLogger(config.AccessLog{}, io.Discard)
+	require.NoError(t, err)
+	router := mux.NewRouter()
+	router.Use(accessLog.LoggingMiddleware)
+	router.Handle("/stream", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})).Name("aborted")
+
+	assert.Panics(t, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+	})
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.HttpRequestsInFlight.WithLabelValues("aborted")))
+}
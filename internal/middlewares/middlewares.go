@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/auth"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/ratelimit"
+	"github.com/arjunksofficial/tyk-task/internal/rediscli"
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/redis/go-redis/v9"
+)
+
+// Middleware wraps a handler with additional behaviour
+type Middleware func(http.Handler) http.Handler
+
+// Factory builds the middleware for a given route
+type Factory func(route config.Route) (Middleware, error)
+
+// Registry maps the middleware names used in config to their factories
+type Registry map[string]Factory
+
+// DefaultRegistry returns the middlewares available to routes, reaching
+// Redis through the shared client
+func DefaultRegistry(cfg *config.Config) Registry {
+	return NewRegistry(cfg, rediscli.GetRedisClient)
+}
+
+// NewRegistry returns the middlewares available to routes. redisClient is
+// only called when a route needs Redis, so routes that do not never connect.
+func NewRegistry(cfg *config.Config, redisClient func() *redis.Client) Registry {
+	tokens := func() tokenservice.Service {
+		return tokenservice.NewWithClient(redisClient(), cfg.Security.APIKeyPepper)
+	}
+	return Registry{
+		"auth": func(route config.Route) (Middleware, error) {
+			authenticator, err := auth.NewAuthenticator(route, cfg.JWT, tokens)
+			if err != nil {
+				return nil, err
+			}
+			if authenticator == nil {
+				// keyless routes pass through
+				return func(next http.Handler) http.Handler { return next }, nil
+			}
+			a := &auth.AuthMiddleware{Authenticator: authenticator, Route: route.GetName()}
+			return a.AuthMiddleware, nil
+		},
+		"ratelimit": func(route config.Route) (Middleware, error) {
+			if !ratelimit.IsSupported(route.RateLimit.Algorithm) {
+				return nil, fmt.Errorf("unknown rate limit algorithm %q", route.RateLimit.Algorithm)
+			}
+			rl := ratelimit.NewRateLimitMiddlewareWithClient(redisClient(), tokens())
+			rl.Algorithm = route.RateLimit.Algorithm
+			rl.Route = route.GetName()
+			rl.TokenLabel = cfg.Metrics.TokenLabel
+			rl.Header = route.Auth.Header
+			rl.QueryParam = route.Auth.QueryParam
+			return rl.RateLimitHandler, nil
+		},
+	}
+}
+
+// Build resolves the route's middleware chain into a single middleware.
+// The first middleware in the chain is the outermost one.
+func (reg Registry) Build(route config.Route) (Middleware, error) {
+	names := route.GetMiddlewares()
+	chain := make([]Middleware, 0, len(names))
+	for _, name := range names {
+		factory, ok := reg[name]
+		if !ok {
+			return nil, fmt.Errorf("route %s: unknown middleware %q", route.GetName(), name)
+		}
+		mw, err := factory(route)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: building middleware %q: %w", route.GetName(), name, err)
+		}
+		chain = append(chain, mw)
+	}
+	return func(next http.Handler) http.Handler {
+		for i := len(chain) - 1; i >= 0; i-- {
+			next = chain[i](next)
+		}
+		return next
+	}, nil
+}
+
+// Validate reports every middleware named by routes that is missing from
+// the registry
+func (reg Registry) Validate(routes []config.Route) error {
+	var errs []error
+	for i, route := range routes {
+		for _, name := range route.GetMiddlewares() {
+			if _, ok := reg[name]; !ok {
+				errs = append(errs, fmt.Errorf("routes[%d].middlewares: unknown middleware %q", i, name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
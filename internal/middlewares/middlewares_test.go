@@ -0,0 +1,203 @@
+package middlewares_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tagging(tag string) middlewares.Factory {
+	return func(config.Route) (middlewares.Middleware, error) {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", tag)
+				next.ServeHTTP(w, r)
+			})
+		}, nil
+	}
+}
+
+func TestRegistry_Build(t *testing.T) {
+	registry := middlewares.Registry{
+		"first":  tagging("first"),
+		"second": tagging("second"),
+	}
+
+	testCases := []struct {
+		desc          string
+		middlewares   []string
+		expectedChain []string
+		expectedErr   string
+	}{
+		{
+			desc:          "Test chain is applied in declared order",
+			middlewares:   []string{"second", "first"},
+			expectedChain: []string{"second", "first"},
+		},
+		{
+			desc:          "Test empty chain leaves route public",
+			middlewares:   []string{},
+			expectedChain: nil,
+		},
+		{
+			desc:        "Test unknown middleware",
+			middlewares: []string{"first", "cache"},
+			expectedErr: `route /api/v1/orders/: unknown middleware "cache"`,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			route := config.Route{Path: "/api/v1/orders/", Middlewares: tC.middlewares}
+
+			chain, err := registry.Build(route)
+			if tC.expectedErr != "" {
+				assert.EqualError(t, err, tC.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+				ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/orders/list", nil))
+			assert.Equal(t, tC.expectedChain, rr.Header().Values("X-Chain"))
+		})
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	registry := middlewares.Registry{
+		"auth":      tagging("auth"),
+		"ratelimit": tagging("ratelimit"),
+	}
+
+	testCases := []struct {
+		desc    string
+		routes  []config.Route
+		wantErr string
+	}{
+		{
+			desc: "Test default and declared chains are known",
+			routes: []config.Route{
+				{Path: "/a/"},
+				{Path: "/b/", Middlewares: []string{"auth"}},
+				{Path: "/c/", Middlewares: []string{}},
+			},
+		},
+		{
+			desc: "Test unknown middlewares are all reported",
+			routes: []config.Route{
+				{Path: "/a/", Middlewares: []string{"auth", "cache"}},
+				{Path: "/b/", Middlewares: []string{"cors"}},
+			},
+			wantErr: "routes[0].middlewares: unknown middleware \"cache\"\nroutes[1].middlewares: unknown middleware \"cors\"",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := registry.Validate(tC.routes)
+			if tC.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tC.wantErr)
+		})
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	cfg := &config.Config{}
+	cfg.Security.APIKeyPepper = "pepper"
+	registry := middlewares.NewRegistry(cfg, func() *redis.Client { return client })
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tokens := services.NewWithClient(client, "pepper")
+	require.NoError(t, tokens.StoreToken(context.Background(), models.TokenData{
+		APIKey: "orders_key", RateLimit: 10, ExpiresAt: expiresAt, AllowedRoutes: []string{"/api/v1/orders/*"},
+	}))
+	require.NoError(t, tokens.StoreToken(context.Background(), models.TokenData{
+		APIKey: "users_key", RateLimit: 10, ExpiresAt: expiresAt, AllowedRoutes: []string{"/api/v1/users/*"},
+	}))
+
+	testCases := []struct {
+		desc           string
+		route          config.Route
+		target         string
+		header         string
+		apiKey         string
+		expectedStatus int
+	}{
+		{
+			desc:           "Test auth only route accepts a token allowed on it",
+			route:          config.Route{Path: "/api/v1/orders/", Middlewares: []string{"auth"}},
+			apiKey:         "orders_key",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "Test auth only route rejects a token limited to other routes",
+			route:          config.Route{Path: "/api/v1/orders/", Middlewares: []string{"auth"}},
+			apiKey:         "users_key",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			desc:           "Test default chain rejects a token limited to other routes",
+			route:          config.Route{Path: "/api/v1/orders/"},
+			apiKey:         "users_key",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			desc:           "Test keyless route is public by default",
+			route:          config.Route{Path: "/api/v1/orders/", Auth: config.Auth{Type: "keyless"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "Test ratelimit reads the key from the route's header",
+			route:          config.Route{Path: "/api/v1/orders/", Middlewares: []string{"ratelimit"}, Auth: config.Auth{Header: "X-API-Key"}},
+			header:         "X-API-Key",
+			apiKey:         "orders_key",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "Test ratelimit reads the key from the route's query parameter",
+			route:          config.Route{Path: "/api/v1/orders/", Middlewares: []string{"ratelimit"}, Auth: config.Auth{QueryParam: "api_key"}},
+			target:         "/api/v1/orders/1?api_key=orders_key",
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			chain, err := registry.Build(tC.route)
+			require.NoError(t, err)
+			target := tC.target
+			if target == "" {
+				target = "/api/v1/orders/1"
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			switch {
+			case tC.header != "":
+				req.Header.Set(tC.header, tC.apiKey)
+			case tC.apiKey != "":
+				req.Header.Set("Authorization", "Bearer "+tC.apiKey)
+			}
+			rr := httptest.NewRecorder()
+
+			chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
+)
+
+// fixedWindowLimiter counts requests per aligned window, e.g. per calendar
+// minute
+type fixedWindowLimiter struct {
+	tokenService tokenservice.Service
+}
+
+// NewFixedWindowLimiter creates a limiter backed by the token service counter
+func NewFixedWindowLimiter(tokenService tokenservice.Service) Limiter {
+	return &fixedWindowLimiter{tokenService: tokenService}
+}
+
+func (l *fixedWindowLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	status, err := l.tokenService.ConsumeRateLimit(ctx, key, int64(policy.Limit), policy.Window)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    status.Allowed,
+		Limit:      policy.Limit,
+		Remaining:  int(status.Remaining),
+		ResetAfter: time.Until(status.ResetAt),
+	}, nil
+}
@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// setHeaders advertises the limiter's window state to the client using both
+// the legacy X-RateLimit-* headers and the IETF RateLimit-* draft fields.
+// X-RateLimit-Reset is a Unix timestamp while RateLimit-Reset is the number
+// of seconds until the quota resets.
+func setHeaders(h http.Header, result Result, now time.Time) {
+	limit := strconv.Itoa(result.Limit)
+	remaining := strconv.Itoa(result.Remaining)
+	resetAfter := seconds(result.ResetAfter)
+
+	h.Set("X-RateLimit-Limit", limit)
+	h.Set("X-RateLimit-Remaining", remaining)
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(time.Duration(resetAfter)*time.Second).Unix(), 10))
+
+	h.Set("RateLimit-Limit", limit)
+	h.Set("RateLimit-Remaining", remaining)
+	h.Set("RateLimit-Reset", strconv.FormatInt(resetAfter, 10))
+
+	if !result.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(resetAfter, 10))
+	}
+}
+
+// seconds rounds a duration up to whole seconds so clients never retry early
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
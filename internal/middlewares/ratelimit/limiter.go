@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+)
+
+// Supported rate limiting algorithms
+const (
+	FixedWindow    = "fixed_window"
+	SlidingLog     = "sliding_log"
+	SlidingCounter = "sliding_counter"
+	TokenBucket    = "token_bucket"
+)
+
+// Policy describes how many requests a key may make in a window
+type Policy struct {
+	Limit  int
+	Window time.Duration
+	// Burst and RefillRate (tokens per second) are only used by the token
+	// bucket algorithm
+	Burst      int
+	RefillRate float64
+}
+
+// Result is the outcome of a single limiter decision
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key is within its policy
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// IsSupported reports whether the algorithm name is known. An empty name
+// selects the default fixed window algorithm.
+func IsSupported(algorithm string) bool {
+	switch algorithm {
+	case "", FixedWindow, SlidingLog, SlidingCounter, TokenBucket:
+		return true
+	}
+	return false
+}
+
+// ValidateToken reports token settings no limiter can work with: an
+// unknown algorithm, or a token bucket without a positive refill rate or
+// rate limit to refill from. Tokens with a bucket capacity use the token
+// bucket.
+func ValidateToken(token models.TokenData) error {
+	if !IsSupported(token.RateLimitAlgorithm) {
+		return fmt.Errorf("unknown rate_limit_algorithm %q", token.RateLimitAlgorithm)
+	}
+	if algorithmFor(token, "") == TokenBucket && token.RefillRate <= 0 && token.RateLimit <= 0 {
+		return errors.New("token bucket needs a positive refill_rate or rate_limit")
+	}
+	return nil
+}
+
+// limiter returns the limiter registered for the algorithm
+func (rl *RateLimitMiddleware) limiter(algorithm string) (Limiter, error) {
+	if algorithm == "" {
+		algorithm = FixedWindow
+	}
+	if l, ok := rl.Limiters[algorithm]; ok {
+		return l, nil
+	}
+	if algorithm == FixedWindow {
+		return NewFixedWindowLimiter(rl.TokenService), nil
+	}
+	return nil, fmt.Errorf("unknown rate limit algorithm %q", algorithm)
+}
+
+func remaining(limit, count int) int {
+	if count >= limit {
+		return 0
+	}
+	return limit - count
+}
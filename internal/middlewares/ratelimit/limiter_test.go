@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type step struct {
+	at                time.Duration
+	expectedAllowed   bool
+	expectedRemaining int
+}
+
+func runSteps(t *testing.T, newLimiter func(redis.Scripter) Limiter, steps []step) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newLimiter(client)
+	policy := Policy{Limit: 3, Window: time.Minute}
+
+	for i, s := range steps {
+		mr.SetTime(start.Add(s.at))
+		result, err := limiter.Allow(context.Background(), "api_key", policy)
+		assert.NoError(t, err)
+		assert.Equal(t, s.expectedAllowed, result.Allowed, "step %d allowed", i)
+		assert.Equal(t, s.expectedRemaining, result.Remaining, "step %d remaining", i)
+		assert.Equal(t, 3, result.Limit)
+		assert.True(t, result.ResetAfter > 0 && result.ResetAfter <= time.Minute, "step %d reset %s", i, result.ResetAfter)
+	}
+}
+
+func TestSlidingLogLimiter_Allow(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		steps []step
+	}{
+		{
+			desc: "Test requests beyond the limit are rejected",
+			steps: []step{
+				{at: 0, expectedAllowed: true, expectedRemaining: 2},
+				{at: time.Second, expectedAllowed: true, expectedRemaining: 1},
+				{at: 2 * time.Second, expectedAllowed: true, expectedRemaining: 0},
+				{at: 3 * time.Second, expectedAllowed: false, expectedRemaining: 0},
+			},
+		},
+		{
+			desc: "Test no burst across minute boundary",
+			steps: []step{
+				{at: 58 * time.Second, expectedAllowed: true, expectedRemaining: 2},
+				{at: 58 * time.Second, expectedAllowed: true, expectedRemaining: 1},
+				{at: 59 * time.Second, expectedAllowed: true, expectedRemaining: 0},
+				{at: 61 * time.Second, expectedAllowed: false, expectedRemaining: 0},
+				{at: 118*time.Second + time.Millisecond, expectedAllowed: true, expectedRemaining: 1},
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			runSteps(t, NewSlidingLogLimiter, tC.steps)
+		})
+	}
+}
+
+func TestSlidingCounterLimiter_Allow(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		steps []step
+	}{
+		{
+			desc: "Test requests beyond the limit are rejected",
+			steps: []step{
+				{at: 0, expectedAllowed: true, expectedRemaining: 2},
+				{at: time.Second, expectedAllowed: true, expectedRemaining: 1},
+				{at: 2 * time.Second, expectedAllowed: true, expectedRemaining: 0},
+				{at: 3 * time.Second, expectedAllowed: false, expectedRemaining: 0},
+			},
+		},
+		{
+			desc: "Test previous window is weighted into the estimate",
+			steps: []step{
+				{at: 50 * time.Second, expectedAllowed: true, expectedRemaining: 2},
+				{at: 55 * time.Second, expectedAllowed: true, expectedRemaining: 1},
+				{at: 59 * time.Second, expectedAllowed: true, expectedRemaining: 0},
+				// 3 * 50/60 = 2.5 previous requests still count
+				{at: 70 * time.Second, expectedAllowed: false, expectedRemaining: 0},
+				// 3 * 15/60 = 0.75, so two more fit
+				{at: 105 * time.Second, expectedAllowed: true, expectedRemaining: 1},
+				{at: 105 * time.Second, expectedAllowed: true, expectedRemaining: 0},
+				{at: 105 * time.Second, expectedAllowed: false, expectedRemaining: 0},
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			runSteps(t, NewSlidingCounterLimiter, tC.steps)
+		})
+	}
+}
+
+func TestTokenBucketLimiter_Allow(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewTokenBucketLimiter(client)
+	policy := Policy{Limit: 300, Window: time.Minute, Burst: 50, RefillRate: 5}
+
+	allow := func(at time.Duration) Result {
+		mr.SetTime(start.Add(at))
+		result, err := limiter.Allow(context.Background(), "api_key", policy)
+		assert.NoError(t, err)
+		return result
+	}
+
+	// A full bucket absorbs a burst of 50
+	for i := 0; i < 50; i++ {
+		result := allow(0)
+		assert.True(t, result.Allowed, "burst request %d", i)
+		assert.Equal(t, 49-i, result.Remaining)
+		assert.Equal(t, 50, result.Limit)
+	}
+	result := allow(0)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 200*time.Millisecond, result.ResetAfter)
+
+	// Refilling at 5/s gives one token every 200ms
+	result = allow(200 * time.Millisecond)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.False(t, allow(300*time.Millisecond).Allowed)
+
+	// After 10s the bucket is full again but never above capacity
+	result = allow(20 * time.Second)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 49, result.Remaining)
+}
+
+func TestTokenBucketLimiter_DefaultsToWindowLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	limiter := NewTokenBucketLimiter(client)
+	policy := Policy{Limit: 2, Window: time.Minute}
+
+	for _, expected := range []bool{true, true, false} {
+		result, err := limiter.Allow(context.Background(), "api_key", policy)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result.Allowed)
+		assert.Equal(t, 2, result.Limit)
+	}
+}
+
+func TestTokenBucketLimiter_ZeroRate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	limiter := NewTokenBucketLimiter(client)
+	result, err := limiter.Allow(context.Background(), "api_key", Policy{Window: time.Minute, Burst: 5})
+	assert.NoError(t, err, "a bucket that never refills is not a Redis error")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 5, result.Limit)
+}
+
+func TestValidateToken(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		token   models.TokenData
+		wantErr string
+	}{
+		{desc: "Test fixed window with zero limit", token: models.TokenData{}},
+		{desc: "Test bucket refilled at the rate limit", token: models.TokenData{RateLimit: 60, BurstCapacity: 10}},
+		{desc: "Test bucket with refill rate", token: models.TokenData{BurstCapacity: 10, RefillRate: 1}},
+		{desc: "Test bucket that never refills", token: models.TokenData{BurstCapacity: 10}, wantErr: "refill_rate or rate_limit"},
+		{desc: "Test token bucket algorithm without rate", token: models.TokenData{RateLimitAlgorithm: TokenBucket}, wantErr: "refill_rate or rate_limit"},
+		{desc: "Test unknown algorithm", token: models.TokenData{RateLimit: 10, RateLimitAlgorithm: "leaky_bucket"}, wantErr: `unknown rate_limit_algorithm "leaky_bucket"`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := ValidateToken(tC.token)
+			if tC.wantErr != "" {
+				assert.ErrorContains(t, err, tC.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -2,101 +2,173 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/arjunksofficial/tyk-task/internal/audit"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/auth"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/arjunksofficial/tyk-task/internal/rediscli"
 	"github.com/arjunksofficial/tyk-task/internal/token/models"
 	tokenservice "github.com/arjunksofficial/tyk-task/internal/token/services"
-	"github.com/go-redis/redis"
+	"github.com/arjunksofficial/tyk-task/internal/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // RateLimitMiddleware is a middleware that limits the number of requests per API key
 type RateLimitMiddleware struct {
 	TokenService tokenservice.Service
+	// Limiters holds the available algorithms keyed by name. The fixed
+	// window limiter is always available through TokenService.
+	Limiters map[string]Limiter
+	// Algorithm is the route default, overridden by the token's own choice
+	Algorithm string
+	// Route names the route in metrics
+	Route string
+	// TokenLabel labels rejections with the API key hash in metrics
+	TokenLabel bool
+	// Header and QueryParam carry the API key looked up when no earlier
+	// middleware authenticated the request, as for the auth middleware
+	Header     string
+	QueryParam string
 }
 
 // NewRateLimitMiddleware creates a new RateLimitMiddleware
 func NewRateLimitMiddleware() *RateLimitMiddleware {
+	return NewRateLimitMiddlewareWithClient(rediscli.GetRedisClient(), tokenservice.New())
+}
+
+// NewRateLimitMiddlewareWithClient creates a RateLimitMiddleware whose
+// limiters use the given Redis client and token service
+func NewRateLimitMiddlewareWithClient(redisCli *redis.Client, tokenService tokenservice.Service) *RateLimitMiddleware {
 	return &RateLimitMiddleware{
-		TokenService: tokenservice.New(),
+		TokenService: tokenService,
+		Limiters: map[string]Limiter{
+			FixedWindow:    NewFixedWindowLimiter(tokenService),
+			SlidingLog:     NewSlidingLogLimiter(redisCli),
+			SlidingCounter: NewSlidingCounterLimiter(redisCli),
+			TokenBucket:    NewTokenBucketLimiter(redisCli),
+		},
 	}
 }
 
 // RateLimitHandler is the middleware handler that checks the rate limit
 func (rl *RateLimitMiddleware) RateLimitHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := r.Header.Get("Authorization")
-		// Check if the API key is present
-		if apiKey == "" {
-			http.Error(w, "Unauthorized: API key is missing", http.StatusUnauthorized)
-			return
-		}
-		// remove Bearer prefix if present
-		if len(apiKey) > 7 && apiKey[:7] == "Bearer " {
-			apiKey = apiKey[7:]
-		}
-		token, err := rl.TokenService.GetToken(r.Context(), apiKey)
-		if err != nil {
-			if err == redis.Nil {
-				http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
-			} else {
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		// Reuse the token authenticated earlier in the chain, e.g. from a JWT
+		token, ok := r.Context().Value(models.TokenContextKey).(models.TokenData)
+		if !ok {
+			if token, ok = rl.lookupToken(w, r); !ok {
+				return
 			}
-			return
-		}
-
-		expiryTime, err := time.Parse(time.RFC3339, token.ExpiresAt)
-		if err != nil {
-			http.Error(w, "Internal Server Error: Invalid token expiry format", http.StatusInternalServerError)
-			return
-		}
-		// Check if the token is expired
-		if time.Now().UTC().After(expiryTime) {
-			http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
-			return
+			logging.SetAPIKey(r.Context(), token.KeyHash)
 		}
 
 		// Check if route is allowed
-		allowed := false
-		for _, pattern := range token.AllowedRoutes {
-			if matchPath(pattern, r.URL.Path) {
-				allowed = true
-				break
-			}
+		if !auth.AllowsRoute(token, r.URL.Path) {
+			rl.authFailure(w, r, token.KeyHash, audit.ReasonRouteForbidden, "Route not allowed for this token", http.StatusForbidden)
+			return
 		}
 
-		if !allowed {
-			http.Error(w, "Route not allowed for this token", http.StatusForbidden)
+		limiter, err := rl.limiter(algorithmFor(token, rl.Algorithm))
+		if err != nil {
+			rl.limited(w, r, token, audit.ReasonBackendError, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		// Fixed window key: token:<api_key>:rate:<YYYYMMDDHHMM>
-		window := time.Now().UTC().Format("200601021504")
-		rateKey := "ratelimit:" + apiKey + ":" + window
-
-		// Increment the rate limit count in Redis
-		count, err := rl.TokenService.IncrementRateLimit(r.Context(), rateKey)
+		ctx, span := tracing.Start(r.Context(), "ratelimit.allow",
+			attribute.String("ratelimit.algorithm", algorithmFor(token, rl.Algorithm)))
+		result, err := limiter.Allow(ctx, token.RateLimitKey(), Policy{
+			Limit:      token.RateLimit,
+			Window:     time.Minute,
+			Burst:      token.BurstCapacity,
+			RefillRate: token.RefillRate,
+		})
+		span.SetAttributes(attribute.Bool("ratelimit.allowed", result.Allowed))
+		tracing.End(span, err)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			rl.limited(w, r, token, audit.ReasonBackendError, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		if int(count) > token.RateLimit {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		setHeaders(w.Header(), result, time.Now())
+		if !result.Allowed {
+			rl.limited(w, r, token, audit.ReasonQuotaExceeded, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
-		ctx := r.Context()
+		ctx = r.Context()
 		ctx = context.WithValue(ctx, models.TokenContextKey, token)
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	})
 }
 
-// Simple wildcard matcher: "/api/v1/users/*" matches "/api/v1/users/123"
-func matchPath(pattern, path string) bool {
-	if strings.HasSuffix(pattern, "/*") {
-		return strings.HasPrefix(path, strings.TrimSuffix(pattern, "*"))
+// lookupToken authenticates the request's API key when no earlier
+// middleware did, writing the error response when it fails
+func (rl *RateLimitMiddleware) lookupToken(w http.ResponseWriter, r *http.Request) (models.TokenData, bool) {
+	apiKey := auth.KeyFromRequest(r, rl.Header, rl.QueryParam)
+	// Check if the API key is present
+	if apiKey == "" {
+		rl.authFailure(w, r, "", audit.ReasonMissingKey, "Unauthorized: API key is missing", http.StatusUnauthorized)
+		return models.TokenData{}, false
+	}
+	ctx, span := tracing.Start(r.Context(), "auth.lookup")
+	token, err := rl.TokenService.GetToken(ctx, apiKey)
+	tracing.End(span, err)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			rl.authFailure(w, r, "", audit.ReasonUnknownKey, "Unauthorized: Invalid API key", http.StatusUnauthorized)
+		} else {
+			rl.authFailure(w, r, "", audit.ReasonBackendError, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return models.TokenData{}, false
+	}
+
+	expiryTime, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil {
+		rl.authFailure(w, r, token.KeyHash, audit.ReasonBackendError, "Internal Server Error: Invalid token expiry format", http.StatusInternalServerError)
+		return models.TokenData{}, false
+	}
+	// Check if the token is expired
+	if time.Now().UTC().After(expiryTime) {
+		rl.authFailure(w, r, token.KeyHash, audit.ReasonExpired, "Unauthorized: Invalid API key", http.StatusUnauthorized)
+		return models.TokenData{}, false
+	}
+	return token, true
+}
+
+// algorithmFor picks the token's algorithm over the route default. Tokens
+// carrying a bucket capacity use the token bucket unless they say otherwise.
+func algorithmFor(token models.TokenData, routeAlgorithm string) string {
+	if token.RateLimitAlgorithm != "" {
+		return token.RateLimitAlgorithm
+	}
+	if token.BurstCapacity > 0 {
+		return TokenBucket
+	}
+	return routeAlgorithm
+}
+
+// authFailure records a request refused before it reached the limiter and
+// writes the error response
+func (rl *RateLimitMiddleware) authFailure(w http.ResponseWriter, r *http.Request, keyHash, reason, msg string, code int) {
+	metrics.AuthFailures.WithLabelValues(rl.Route, reason).Inc()
+	audit.Record(r, audit.Event{Route: rl.Route, Reason: reason, Status: code, KeyHash: keyHash})
+	requestid.Error(w, r, msg, code)
+}
+
+// limited records a request refused by the limiter and writes the error
+// response
+func (rl *RateLimitMiddleware) limited(w http.ResponseWriter, r *http.Request, token models.TokenData, reason, msg string, code int) {
+	var label string
+	if rl.TokenLabel {
+		label = token.KeyHash
 	}
-	return pattern == path
+	metrics.RateLimitHits.WithLabelValues(rl.Route, reason, label).Inc()
+	audit.Record(r, audit.Event{Route: rl.Route, Reason: reason, Status: code, KeyHash: token.KeyHash})
+	requestid.Error(w, r, msg, code)
 }
@@ -1,16 +1,22 @@
 package ratelimit_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/arjunksofficial/tyk-task/internal/audit"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
 	"github.com/arjunksofficial/tyk-task/internal/middlewares/ratelimit"
 	"github.com/arjunksofficial/tyk-task/internal/token/models"
 	"github.com/arjunksofficial/tyk-task/internal/token/services"
-	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,7 +44,7 @@ func TestRateLimitMiddleware_RateLimitHandler(t *testing.T) {
 					ExpiresAt:     validTimeStamp,
 					AllowedRoutes: []string{"/api/v1/resource"},
 				}, nil)
-				mockTokenSvc.On("IncrementRateLimit", mock.Anything, mock.Anything).Return(int64(2), nil)
+				mockTokenSvc.On("ConsumeRateLimit", mock.Anything, "valid_api_key", int64(100), time.Minute).Return(models.RateLimitStatus{Allowed: true, Count: 2, Limit: 100, Remaining: 98, ResetAt: currentTime.Add(time.Minute)}, nil)
 				return mockTokenSvc
 			}(),
 			expectedStatus: http.StatusOK,
@@ -114,7 +120,7 @@ func TestRateLimitMiddleware_RateLimitHandler(t *testing.T) {
 					ExpiresAt:     validTimeStamp,
 					AllowedRoutes: []string{"/api/v1/resource"},
 				}, nil)
-				mockTokenSvc.On("IncrementRateLimit", mock.Anything, mock.Anything).Return(int64(2), nil)
+				mockTokenSvc.On("ConsumeRateLimit", mock.Anything, "rate_limit_exceeded_api_key", int64(1), time.Minute).Return(models.RateLimitStatus{Allowed: false, Count: 2, Limit: 1, Remaining: 0, ResetAt: currentTime.Add(time.Minute)}, nil)
 				return mockTokenSvc
 			}(),
 			expectedStatus: http.StatusTooManyRequests,
@@ -178,7 +184,7 @@ func TestRateLimitMiddleware_RateLimitHandler(t *testing.T) {
 					ExpiresAt:     validTimeStamp,
 					AllowedRoutes: []string{"/api/v1/resource", "/api/v1/another"},
 				}, nil)
-				mockTokenSvc.On("IncrementRateLimit", mock.Anything, mock.Anything).Return(int64(2), nil)
+				mockTokenSvc.On("ConsumeRateLimit", mock.Anything, "multi_routes_api_key", int64(100), time.Minute).Return(models.RateLimitStatus{Allowed: true, Count: 2, Limit: 100, Remaining: 98, ResetAt: currentTime.Add(time.Minute)}, nil)
 				return mockTokenSvc
 			}(),
 			expectedStatus: http.StatusOK,
@@ -196,7 +202,7 @@ func TestRateLimitMiddleware_RateLimitHandler(t *testing.T) {
 					ExpiresAt:     validTimeStamp,
 					AllowedRoutes: []string{"/api/v1/resource"},
 				}, nil)
-				mockTokenSvc.On("IncrementRateLimit", mock.Anything, mock.Anything).Return(int64(0), nil)
+				mockTokenSvc.On("ConsumeRateLimit", mock.Anything, "reset_rate_limit_api_key", int64(100), time.Minute).Return(models.RateLimitStatus{Allowed: true, Count: 1, Limit: 100, Remaining: 99, ResetAt: currentTime.Add(time.Minute)}, nil)
 				return mockTokenSvc
 			}(),
 			expectedStatus: http.StatusOK,
@@ -214,7 +220,7 @@ func TestRateLimitMiddleware_RateLimitHandler(t *testing.T) {
 					ExpiresAt:     validTimeStamp,
 					AllowedRoutes: []string{"/api/v1/resource"},
 				}, nil)
-				mockTokenSvc.On("IncrementRateLimit", mock.Anything, mock.Anything).Return(int64(2), errors.New("some error"))
+				mockTokenSvc.On("ConsumeRateLimit", mock.Anything, "valid_api_key", int64(100), time.Minute).Return(models.RateLimitStatus{}, errors.New("some error"))
 				return mockTokenSvc
 			}(),
 			expectedStatus: http.StatusInternalServerError,
@@ -260,3 +266,298 @@ func TestRateLimitMiddleware_RateLimitHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimitMiddleware_Algorithm(t *testing.T) {
+	validTimeStamp := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	testCases := []struct {
+		desc            string
+		routeAlgorithm  string
+		tokenAlgorithm  string
+		tokenBurst      int
+		expectedLimiter string
+		expectedStatus  int
+	}{
+		{
+			desc:            "Test route algorithm is used by default",
+			routeAlgorithm:  ratelimit.SlidingLog,
+			expectedLimiter: ratelimit.SlidingLog,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			desc:            "Test token algorithm overrides route",
+			routeAlgorithm:  ratelimit.SlidingLog,
+			tokenAlgorithm:  ratelimit.SlidingCounter,
+			expectedLimiter: ratelimit.SlidingCounter,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			desc:            "Test token with bucket capacity uses token bucket",
+			routeAlgorithm:  ratelimit.SlidingLog,
+			tokenBurst:      50,
+			expectedLimiter: ratelimit.TokenBucket,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			desc:           "Test unknown token algorithm",
+			tokenAlgorithm: "leaky_bucket",
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mockTokenSvc := services.NewMockService(t)
+			mockTokenSvc.On("GetToken", mock.Anything, "valid_api_key").Return(models.TokenData{
+				APIKey:             "valid_api_key",
+				RateLimit:          10,
+				ExpiresAt:          validTimeStamp,
+				AllowedRoutes:      []string{"/api/v1/resource"},
+				RateLimitAlgorithm: tC.tokenAlgorithm,
+				BurstCapacity:      tC.tokenBurst,
+			}, nil)
+
+			limiters := map[string]ratelimit.Limiter{}
+			for _, name := range []string{ratelimit.SlidingLog, ratelimit.SlidingCounter, ratelimit.TokenBucket} {
+				limiter := ratelimit.NewMockLimiter(t)
+				if name == tC.expectedLimiter {
+					limiter.On("Allow", mock.Anything, "valid_api_key", ratelimit.Policy{Limit: 10, Window: time.Minute, Burst: tC.tokenBurst}).
+						Return(ratelimit.Result{Allowed: true, Limit: 10, Remaining: 9}, nil)
+				}
+				limiters[name] = limiter
+			}
+
+			rl := ratelimit.RateLimitMiddleware{
+				TokenService: mockTokenSvc,
+				Limiters:     limiters,
+				Algorithm:    tC.routeAlgorithm,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+			req.Header.Set("Authorization", "Bearer valid_api_key")
+			rr := httptest.NewRecorder()
+
+			rl.RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestRateLimitMiddleware_Headers(t *testing.T) {
+	validTimeStamp := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	testCases := []struct {
+		desc            string
+		result          ratelimit.Result
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			desc:           "Test headers on allowed request",
+			result:         ratelimit.Result{Allowed: true, Limit: 10, Remaining: 7, ResetAfter: 42 * time.Second},
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"X-RateLimit-Limit":     "10",
+				"X-RateLimit-Remaining": "7",
+				"RateLimit-Limit":       "10",
+				"RateLimit-Remaining":   "7",
+				"RateLimit-Reset":       "42",
+				"Retry-After":           "",
+			},
+		},
+		{
+			desc:           "Test Retry-After on rejected request",
+			result:         ratelimit.Result{Allowed: false, Limit: 10, Remaining: 0, ResetAfter: 1500 * time.Millisecond},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedHeaders: map[string]string{
+				"X-RateLimit-Limit":     "10",
+				"X-RateLimit-Remaining": "0",
+				"RateLimit-Limit":       "10",
+				"RateLimit-Remaining":   "0",
+				"RateLimit-Reset":       "2",
+				"Retry-After":           "2",
+			},
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mockTokenSvc := services.NewMockService(t)
+			mockTokenSvc.On("GetToken", mock.Anything, "valid_api_key").Return(models.TokenData{
+				APIKey:        "valid_api_key",
+				RateLimit:     10,
+				ExpiresAt:     validTimeStamp,
+				AllowedRoutes: []string{"/api/v1/resource"},
+			}, nil)
+			limiter := ratelimit.NewMockLimiter(t)
+			limiter.On("Allow", mock.Anything, "valid_api_key", mock.Anything).Return(tC.result, nil)
+
+			rl := ratelimit.RateLimitMiddleware{
+				TokenService: mockTokenSvc,
+				Limiters:     map[string]ratelimit.Limiter{ratelimit.FixedWindow: limiter},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+			req.Header.Set("Authorization", "Bearer valid_api_key")
+			rr := httptest.NewRecorder()
+
+			before := time.Now()
+			rl.RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+			for header, expected := range tC.expectedHeaders {
+				assert.Equal(t, expected, rr.Header().Get(header), header)
+			}
+			reset, err := strconv.ParseInt(rr.Header().Get("X-RateLimit-Reset"), 10, 64)
+			assert.NoError(t, err)
+			assert.InDelta(t, before.Add(tC.result.ResetAfter).Unix(), reset, 2)
+		})
+	}
+}
+
+func TestRateLimitMiddleware_TokenFromContext(t *testing.T) {
+	// e.g. a token built from JWT claims by the auth middleware
+	token := models.TokenData{
+		KeyHash:       "jwt:orders-service",
+		RateLimit:     20,
+		AllowedRoutes: []string{"/api/v1/resource"},
+	}
+	limiter := ratelimit.NewMockLimiter(t)
+	limiter.On("Allow", mock.Anything, "jwt:orders-service", ratelimit.Policy{Limit: 20, Window: time.Minute}).
+		Return(ratelimit.Result{Allowed: true, Limit: 20, Remaining: 19}, nil)
+
+	rl := ratelimit.RateLimitMiddleware{
+		TokenService: services.NewMockService(t),
+		Limiters:     map[string]ratelimit.Limiter{ratelimit.FixedWindow: limiter},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+	req = req.WithContext(context.WithValue(req.Context(), models.TokenContextKey, token))
+	rr := httptest.NewRecorder()
+	called := false
+
+	rl.RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, called, "Final handler should have been called")
+	assert.Equal(t, "19", rr.Header().Get("X-RateLimit-Remaining"))
+}
+
+func TestRateLimitMiddleware_Metrics(t *testing.T) {
+	validTimeStamp := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	expiredTimeStamp := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	token := models.TokenData{KeyHash: "key-hash", RateLimit: 1, ExpiresAt: validTimeStamp, AllowedRoutes: []string{"/api/v1/resource"}}
+
+	testCases := []struct {
+		desc       string
+		apiKey     string
+		token      models.TokenData
+		tokenErr   error
+		result     ratelimit.Result
+		allowErr   error
+		tokenLabel bool
+		status     int
+		reason     string
+		// limited rejections are counted by RateLimitHits, the others by
+		// AuthFailures
+		limited bool
+	}{
+		{
+			desc:   "Test missing key counted as auth failure",
+			status: http.StatusUnauthorized,
+			reason: audit.ReasonMissingKey,
+		},
+		{
+			desc:     "Test unknown key counted as auth failure",
+			apiKey:   "unknown",
+			tokenErr: redis.Nil,
+			status:   http.StatusUnauthorized,
+			reason:   audit.ReasonUnknownKey,
+		},
+		{
+			desc:     "Test token store error counted as auth failure",
+			apiKey:   "key",
+			tokenErr: errors.New("connection refused"),
+			status:   http.StatusInternalServerError,
+			reason:   audit.ReasonBackendError,
+		},
+		{
+			desc:   "Test expired key counted as auth failure",
+			apiKey: "key",
+			token:  models.TokenData{KeyHash: "key-hash", ExpiresAt: expiredTimeStamp},
+			status: http.StatusUnauthorized,
+			reason: audit.ReasonExpired,
+		},
+		{
+			desc:   "Test forbidden route counted as auth failure",
+			apiKey: "key",
+			token:  models.TokenData{KeyHash: "key-hash", ExpiresAt: validTimeStamp, AllowedRoutes: []string{"/api/v1/other"}},
+			status: http.StatusForbidden,
+			reason: audit.ReasonRouteForbidden,
+		},
+		{
+			desc:    "Test exceeded quota counted as rate limit hit",
+			apiKey:  "key",
+			token:   token,
+			result:  ratelimit.Result{Limit: 1},
+			status:  http.StatusTooManyRequests,
+			reason:  audit.ReasonQuotaExceeded,
+			limited: true,
+		},
+		{
+			desc:       "Test rate limit hit labelled with the token when opted in",
+			apiKey:     "key",
+			token:      token,
+			result:     ratelimit.Result{Limit: 1},
+			tokenLabel: true,
+			status:     http.StatusTooManyRequests,
+			reason:     audit.ReasonQuotaExceeded,
+			limited:    true,
+		},
+		{
+			desc:     "Test limiter error counted as rate limit hit",
+			apiKey:   "key",
+			token:    token,
+			allowErr: errors.New("connection refused"),
+			status:   http.StatusInternalServerError,
+			reason:   audit.ReasonBackendError,
+			limited:  true,
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mockTokenSvc := services.NewMockService(t)
+			if tC.apiKey != "" {
+				mockTokenSvc.On("GetToken", mock.Anything, tC.apiKey).Return(tC.token, tC.tokenErr)
+			}
+			limiter := ratelimit.NewMockLimiter(t)
+			if tC.result.Limit > 0 || tC.allowErr != nil {
+				limiter.On("Allow", mock.Anything, "key-hash", mock.Anything).Return(tC.result, tC.allowErr)
+			}
+
+			rl := ratelimit.RateLimitMiddleware{
+				TokenService: mockTokenSvc,
+				Limiters:     map[string]ratelimit.Limiter{ratelimit.FixedWindow: limiter},
+				Route:        fmt.Sprintf("metrics-%d", i),
+				TokenLabel:   tC.tokenLabel,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/resource", nil)
+			if tC.apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+tC.apiKey)
+			}
+			rr := httptest.NewRecorder()
+			rl.RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+			assert.Equal(t, tC.status, rr.Code)
+			counter := metrics.AuthFailures.WithLabelValues(rl.Route, tC.reason)
+			if tC.limited {
+				var label string
+				if tC.tokenLabel {
+					label = "key-hash"
+				}
+				counter = metrics.RateLimitHits.WithLabelValues(rl.Route, tC.reason, label)
+			}
+			assert.Equal(t, 1.0, testutil.ToFloat64(counter))
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingCounterScript estimates the requests in the sliding window as the
+// current window count plus the previous window count weighted by how much
+// of it still overlaps the sliding window. The counters are fields of one
+// hash, keyed by window number on the Redis clock so that replicas agree on
+// the window. Returns {allowed, estimate, reset_ms}.
+var slidingCounterScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local current = math.floor(now / window)
+local elapsed = now % window
+local reset = window - elapsed
+
+local prev = tonumber(redis.call('HGET', KEYS[1], tostring(current - 1)) or '0')
+local curr = tonumber(redis.call('HGET', KEYS[1], tostring(current)) or '0')
+local estimate = prev * ((window - elapsed) / window) + curr
+if estimate + 1 > limit then
+	return {0, math.ceil(estimate), reset}
+end
+redis.call('HINCRBY', KEYS[1], tostring(current), 1)
+for _, field in ipairs(redis.call('HKEYS', KEYS[1])) do
+	if tonumber(field) < current - 1 then
+		redis.call('HDEL', KEYS[1], field)
+	end
+end
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {1, math.ceil(estimate + 1), reset}
+`)
+
+// slidingCounterLimiter approximates a sliding window with two fixed window
+// counters, using constant memory per key
+type slidingCounterLimiter struct {
+	client redis.Scripter
+}
+
+// NewSlidingCounterLimiter creates a limiter backed by a Redis hash of window counters
+func NewSlidingCounterLimiter(client redis.Scripter) Limiter {
+	return &slidingCounterLimiter{client: client}
+}
+
+func (l *slidingCounterLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	res, err := slidingCounterScript.Run(ctx, l.client,
+		[]string{"ratelimit:counter:" + key},
+		policy.Limit,
+		policy.Window.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  remaining(policy.Limit, int(res[1])),
+		ResetAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingLogScript records one request timestamp in a sorted set after
+// dropping the ones that fell out of the window. Rejected requests are not
+// recorded so that a client hammering the gateway cannot extend its own ban.
+// Timestamps come from the Redis clock so that replicas agree on the window.
+// Returns {allowed, count, reset_ms}.
+var slidingLogScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[3])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// slidingLogLimiter keeps the timestamp of every accepted request in the
+// window, giving exact counts at the cost of one set member per request
+type slidingLogLimiter struct {
+	client redis.Scripter
+}
+
+// NewSlidingLogLimiter creates a limiter backed by a Redis sorted set
+func NewSlidingLogLimiter(client redis.Scripter) Limiter {
+	return &slidingLogLimiter{client: client}
+}
+
+func (l *slidingLogLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	res, err := slidingLogScript.Run(ctx, l.client,
+		[]string{"ratelimit:log:" + key},
+		policy.Window.Milliseconds(),
+		policy.Limit,
+		uuid.New().String(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  remaining(policy.Limit, int(res[1])),
+		ResetAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
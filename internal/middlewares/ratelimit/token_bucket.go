@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills the bucket for the time elapsed since the last
+// request and takes one token out of it. The clock is read from Redis so
+// that gateway replicas with skewed clocks agree on the refill.
+// Returns {allowed, tokens_left, retry_after_ms, reset_ms}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2]) / 1000
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+
+local retry = 0
+if tokens < 1 then
+	retry = math.ceil((1 - tokens) / rate)
+end
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// tokenBucketLimiter allows bursts up to the bucket capacity while holding
+// the sustained rate to the refill rate
+type tokenBucketLimiter struct {
+	client redis.Scripter
+}
+
+// NewTokenBucketLimiter creates a limiter backed by a Redis hash per key
+func NewTokenBucketLimiter(client redis.Scripter) Limiter {
+	return &tokenBucketLimiter{client: client}
+}
+
+func (l *tokenBucketLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	capacity, rate := policy.Burst, policy.RefillRate
+	// Without bucket settings fall back to the per window limit
+	if capacity <= 0 {
+		capacity = policy.Limit
+	}
+	if rate <= 0 {
+		rate = float64(policy.Limit) / policy.Window.Seconds()
+	}
+	// A bucket that never refills would divide by zero in the script;
+	// nothing is allowed, as with a zero limit in the other algorithms
+	if rate <= 0 {
+		return Result{Limit: capacity}, nil
+	}
+
+	res, err := tokenBucketScript.Run(ctx, l.client,
+		[]string{"ratelimit:bucket:" + key},
+		capacity,
+		rate,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	resetAfter := time.Duration(res[3]) * time.Millisecond
+	if res[0] == 0 {
+		resetAfter = time.Duration(res[2]) * time.Millisecond
+	}
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      capacity,
+		Remaining:  int(res[1]),
+		ResetAfter: resetAfter,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package requestid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// DefaultHeader carries the request ID when the config names no other
+const DefaultHeader = "X-Request-ID"
+
+// maxLength bounds the IDs accepted from clients
+const maxLength = 128
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID, or "" when ctx has none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Header is the header carrying the request ID for the configured name
+func Header(name string) string {
+	if name == "" {
+		return DefaultHeader
+	}
+	return name
+}
+
+// New returns the middleware assigning every request an ID. The ID sent by
+// the client in header is kept, otherwise a UUID is generated. It is stored
+// in the request context, forwarded to the upstream in header and echoed on
+// the response.
+func New(header string) func(http.Handler) http.Handler {
+	header = Header(header)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !valid(id) {
+				id = uuid.NewString()
+				r.Header.Set(header, id)
+			}
+			w.Header().Set(header, id)
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+		})
+	}
+}
+
+// valid reports whether a client supplied ID is safe to log and forward:
+// printable ASCII of a sensible length
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Error replies like http.Error, adding the request ID to the message so
+// clients can quote it
+func Error(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	if id := FromContext(r.Context()); id != "" {
+		msg = fmt.Sprintf("%s (request ID: %s)", msg, id)
+	}
+	http.Error(w, msg, code)
+}
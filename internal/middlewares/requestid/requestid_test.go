@@ -0,0 +1,69 @@
+package requestid_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		header    string
+		incoming  string
+		keep      bool
+		generated bool
+	}{
+		{desc: "Test ID generated when missing", generated: true},
+		{desc: "Test client ID kept", incoming: "req-7f3a", keep: true},
+		{desc: "Test ID with spaces replaced", incoming: "req 7f3a\nforged", generated: true},
+		{desc: "Test overlong ID replaced", incoming: strings.Repeat("a", 129), generated: true},
+		{desc: "Test configured header", header: "X-Correlation-ID", incoming: "corr-1", keep: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			header := tC.header
+			if header == "" {
+				header = requestid.DefaultHeader
+			}
+			var inContext, forwarded string
+			handler := requestid.New(tC.header)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inContext = requestid.FromContext(r.Context())
+				forwarded = r.Header.Get(header)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/", nil)
+			if tC.incoming != "" {
+				r.Header.Set(header, tC.incoming)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+
+			if tC.keep {
+				assert.Equal(t, tC.incoming, inContext)
+			}
+			if tC.generated {
+				assert.NoError(t, uuid.Validate(inContext))
+			}
+			assert.Equal(t, inContext, forwarded)
+			assert.Equal(t, inContext, rr.Header().Get(header))
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/", nil)
+	rr := httptest.NewRecorder()
+	requestid.Error(rr, r, "Rate limit exceeded", http.StatusTooManyRequests)
+	assert.Equal(t, "Rate limit exceeded\n", rr.Body.String())
+
+	r = r.WithContext(requestid.NewContext(r.Context(), "req-7f3a"))
+	rr = httptest.NewRecorder()
+	requestid.Error(rr, r, "Rate limit exceeded", http.StatusTooManyRequests)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "Rate limit exceeded (request ID: req-7f3a)\n", rr.Body.String())
+}
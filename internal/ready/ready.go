@@ -1,32 +1,65 @@
 package ready
 
 import (
+	"encoding/json"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/arjunksofficial/tyk-task/internal/config"
 	"github.com/arjunksofficial/tyk-task/internal/rediscli"
 )
 
-func ReadyHandler(w http.ResponseWriter, r *http.Request) {
-	// Respond with a simple message indicating readiness
-	w.WriteHeader(http.StatusOK)
-	_, err := w.Write([]byte("Ready"))
-	if err != nil {
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
-		return
-	}
-	// check for redis connection or any other service readiness checks here
-	cfg := config.GetConfig()
-	if !cfg.IsReady() {
-		http.Error(w, "Service not ready", http.StatusServiceUnavailable)
-		return
-	}
-	err = rediscli.GetRedisClient().Ping(r.Context()).Err() // Check Redis connection
-	if err != nil {
-		http.Error(w, "Redis not ready: "+err.Error(), http.StatusServiceUnavailable)
-		return
+// UpstreamStatus reports whether each upstream target is in rotation, keyed
+// by route name and target URL
+type UpstreamStatus func() map[string]map[string]bool
+
+// draining is set once the gateway is shutting down
+var draining atomic.Bool
+
+// SetDraining fails the readiness check from now on, so load balancers
+// stop sending requests while the ones in flight finish
+func SetDraining() {
+	draining.Store(true)
+}
+
+type response struct {
+	Status    string                       `json:"status"`
+	Error     string                       `json:"error,omitempty"`
+	Upstreams map[string]map[string]string `json:"upstreams,omitempty"`
+}
+
+// NewHandler returns the readiness check. The gateway is ready once its
+// config is loaded and Redis answers. Upstream health is reported in the
+// body but does not fail the check, so an outage behind one route does not
+// take the gateway out of its load balancer.
+func NewHandler(upstreams UpstreamStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := response{Status: "ready"}
+		if upstreams != nil {
+			resp.Upstreams = map[string]map[string]string{}
+			for route, targets := range upstreams() {
+				resp.Upstreams[route] = map[string]string{}
+				for target, healthy := range targets {
+					resp.Upstreams[route][target] = "unhealthy"
+					if healthy {
+						resp.Upstreams[route][target] = "healthy"
+					}
+				}
+			}
+		}
+
+		status := http.StatusOK
+		// check for redis connection or any other service readiness checks here
+		if draining.Load() {
+			status, resp.Status, resp.Error = http.StatusServiceUnavailable, "draining", "Shutting down"
+		} else if cfg := config.GetConfig(); !cfg.IsReady() {
+			status, resp.Status, resp.Error = http.StatusServiceUnavailable, "not ready", "Service not ready"
+		} else if err := rediscli.GetRedisClient().Ping(r.Context()).Err(); err != nil {
+			status, resp.Status, resp.Error = http.StatusServiceUnavailable, "not ready", "Redis not ready: "+err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
 	}
-	w.Write([]byte("Service is ready"))
-	w.Header().Set("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusOK) // Ensure the status code is set to 200 OK
 }
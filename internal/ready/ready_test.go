@@ -0,0 +1,46 @@
+package ready_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/ready"
+	"github.com/arjunksofficial/tyk-task/internal/rediscli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := &config.Config{}
+	cfg.App.Name, cfg.App.Port = "API Gateway", "9000"
+	cfg.Redis.Host, cfg.Redis.Port = mr.Host(), mr.Port()
+	config.SetConfig(cfg)
+	defer rediscli.CloseRedisClient()
+
+	handler := ready.NewHandler(func() map[string]map[string]bool {
+		return map[string]map[string]bool{"orders": {"http://orders:8000": false}}
+	})
+	check := func() (int, map[string]any) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		return w.Code, body
+	}
+
+	// An unhealthy upstream does not fail the check
+	code, body := check()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body["status"])
+	assert.Equal(t, map[string]any{"orders": map[string]any{"http://orders:8000": "unhealthy"}}, body["upstreams"])
+
+	ready.SetDraining()
+	code, body = check()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", body["status"])
+}
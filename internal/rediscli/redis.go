@@ -2,6 +2,7 @@ package rediscli
 
 import (
 	"context"
+	"sync"
 
 	"github.com/arjunksofficial/tyk-task/internal/config"
 	"github.com/redis/go-redis/v9"
@@ -26,10 +27,16 @@ func NewRedisClient() (*redis.Client, error) {
 	return rdb, nil
 }
 
-// GetRedisClient returns a singleton Redis client instance
-var redisClient *redis.Client
+var (
+	// mu guards redisClient, which handlers read while shutdown closes it
+	mu          sync.Mutex
+	redisClient *redis.Client
+)
 
+// GetRedisClient returns a singleton Redis client instance
 func GetRedisClient() *redis.Client {
+	mu.Lock()
+	defer mu.Unlock()
 	if redisClient == nil {
 		var err error
 		redisClient, err = NewRedisClient()
@@ -42,6 +49,8 @@ func GetRedisClient() *redis.Client {
 
 // CloseRedisClient closes the Redis client connection
 func CloseRedisClient() error {
+	mu.Lock()
+	defer mu.Unlock()
 	if redisClient != nil {
 		if err := redisClient.Close(); err != nil {
 			return err
@@ -0,0 +1,186 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/arjunksofficial/tyk-task/internal/tracing"
+	"github.com/arjunksofficial/tyk-task/internal/upstream"
+)
+
+// DefaultBackoff is waited before the first retry when the route sets none
+const DefaultBackoff = 100 * time.Millisecond
+
+// proxy forwards a route's requests to the targets its pool picks,
+// retrying failed attempts when the route allows it
+type proxy struct {
+	pool    *upstream.Pool
+	proxies map[*upstream.Target]*httputil.ReverseProxy
+	timeout time.Duration
+	retries config.Retries
+	// requestIDHeader is already set on the response by the request ID
+	// middleware
+	requestIDHeader string
+}
+
+// attemptKey carries the *attempt of a request to the ReverseProxy hooks
+type attemptKey struct{}
+
+// attempt is a single try at proxying a request
+type attempt struct {
+	// last attempts always write their response
+	last bool
+	// retry is set by the hooks when the attempt failed in a way worth
+	// retrying, leaving the response unwritten
+	retry bool
+	// status of the upstream's response, 0 when there was none
+	status int
+}
+
+var errRetryableStatus = errors.New("retryable status")
+
+func newProxy(route config.Route, pool *upstream.Pool, transport http.RoundTripper, requestIDHeader string) *proxy {
+	p := &proxy{
+		pool:            pool,
+		proxies:         make(map[*upstream.Target]*httputil.ReverseProxy, len(pool.Targets())),
+		timeout:         route.Timeouts.Request,
+		retries:         route.Retries,
+		requestIDHeader: requestIDHeader,
+	}
+	if p.retries.Backoff == 0 {
+		p.retries.Backoff = DefaultBackoff
+	}
+	for _, target := range pool.Targets() {
+		rp := httputil.NewSingleHostReverseProxy(target.URL)
+		rp.Transport = tracing.Transport(transport)
+		rp.ModifyResponse = func(resp *http.Response) error {
+			// Feed the passive health check
+			pool.Report(target, resp.StatusCode < http.StatusInternalServerError)
+			a := resp.Request.Context().Value(attemptKey{}).(*attempt)
+			a.status = resp.StatusCode
+			// The proxy adds the upstream's headers to the gateway's, so an
+			// upstream echoing the request ID would send it twice
+			resp.Header.Del(p.requestIDHeader)
+			if !a.last && slices.Contains(p.retries.OnStatus, resp.StatusCode) {
+				return fmt.Errorf("%w %d", errRetryableStatus, resp.StatusCode)
+			}
+			return nil
+		}
+		rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			// A client going away says nothing about the upstream
+			if !errors.Is(err, errRetryableStatus) && !errors.Is(err, context.Canceled) {
+				pool.Report(target, false)
+			}
+			a := r.Context().Value(attemptKey{}).(*attempt)
+			if !a.last && r.Context().Err() == nil {
+				log.Printf("Retrying %s %s after %s failed: %v [request_id=%s]", r.Method, r.URL.Path, target.URL, err, requestid.FromContext(r.Context()))
+				a.retry = true
+				return
+			}
+			log.Printf("http: proxy error: %v [request_id=%s]", err, requestid.FromContext(r.Context()))
+			if errors.Is(err, context.DeadlineExceeded) {
+				requestid.Error(w, r, "Gateway Timeout", http.StatusGatewayTimeout)
+				return
+			}
+			requestid.Error(w, r, "Bad Gateway", http.StatusBadGateway)
+		}
+		p.proxies[target] = rp
+	}
+	return p
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	// Set once, as forwarding rewrites the host for the target
+	r.Header.Set("X-Forwarded-Host", r.Host)
+	r.Header.Set("X-Forwarded-For", r.RemoteAddr)
+
+	retries := 0
+	if retryable(r) {
+		retries = p.retries.Attempts
+	}
+	backoff := p.retries.Backoff
+	for i := 0; ; i++ {
+		target := p.pool.Next(r)
+		if target == nil {
+			requestid.Error(w, r, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		a := &attempt{last: i == retries}
+		p.forward(w, r, target, a)
+		if !a.retry {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-r.Context().Done():
+			if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+				requestid.Error(w, r, "Gateway Timeout", http.StatusGatewayTimeout)
+			}
+			return
+		}
+	}
+}
+
+// forward makes a single attempt at proxying the request to target
+func (p *proxy) forward(w http.ResponseWriter, r *http.Request, target *upstream.Target, a *attempt) {
+	done := target.Acquire()
+	defer done()
+
+	r.Header.Set("X-Forwarded-Proto", target.URL.Scheme)
+	// Modify the request URL to match the target host
+	r.URL.Host = target.URL.Host
+	r.URL.Scheme = target.URL.Scheme
+	r.Host = target.URL.Host
+
+	route, upstreamURL := p.pool.Name(), target.URL.String()
+	logging.SetUpstream(r.Context(), upstreamURL)
+	inFlight := metrics.UpstreamRequestsInFlight.WithLabelValues(route, upstreamURL)
+	inFlight.Inc()
+	start := time.Now()
+	// Deferred, the reverse proxy panics with http.ErrAbortHandler when the
+	// client goes away while the response is streamed
+	defer func() {
+		inFlight.Dec()
+		class := "error"
+		if a.status != 0 {
+			class = metrics.StatusClass(a.status)
+		}
+		metrics.UpstreamRequestDuration.WithLabelValues(route, upstreamURL, class).Observe(time.Since(start).Seconds())
+	}()
+	p.proxies[target].ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), attemptKey{}, a)))
+}
+
+// retryable reports whether the request can safely be sent again: it must
+// be idempotent and have no body, which the first attempt would consume
+func retryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return r.ContentLength == 0 && (r.Body == nil || r.Body == http.NoBody)
+	}
+	return false
+}
+
+func targetURLs(pool *upstream.Pool) []string {
+	urls := make([]string, 0, len(pool.Targets()))
+	for _, target := range pool.Targets() {
+		urls = append(urls, target.URL.String())
+	}
+	return urls
+}
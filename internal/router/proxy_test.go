@@ -0,0 +1,161 @@
+package router_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Retries(t *testing.T) {
+	// failing answers 503 to the first `fail` requests of each test case
+	var calls, fail atomic.Int64
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= fail.Load() {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer failing.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	retries := config.Retries{Attempts: 2, Backoff: time.Millisecond, OnStatus: []int{http.StatusServiceUnavailable}}
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{Path: "/status/", Host: failing.URL, Retries: retries, Middlewares: []string{}},
+			{
+				Path:        "/down/",
+				Targets:     []config.Target{{URL: closed.URL}, {URL: failing.URL}},
+				Retries:     retries,
+				Middlewares: []string{},
+			},
+		},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc           string
+		method         string
+		path           string
+		body           string
+		fail           int64
+		expectedStatus int
+		expectedCalls  int64
+	}{
+		{
+			desc:           "Test GET is retried on a configured status",
+			method:         http.MethodGet,
+			path:           "/status/list",
+			fail:           2,
+			expectedStatus: http.StatusOK,
+			expectedCalls:  3,
+		},
+		{
+			desc:           "Test last attempt's response is returned",
+			method:         http.MethodGet,
+			path:           "/status/list",
+			fail:           5,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCalls:  3,
+		},
+		{
+			desc:           "Test POST is not retried",
+			method:         http.MethodPost,
+			path:           "/status/list",
+			fail:           1,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCalls:  1,
+		},
+		{
+			desc:           "Test PUT with a body is not retried",
+			method:         http.MethodPut,
+			path:           "/status/list",
+			body:           `{"name":"orders"}`,
+			fail:           1,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCalls:  1,
+		},
+		{
+			desc:           "Test connection error is retried on the next target",
+			method:         http.MethodGet,
+			path:           "/down/list",
+			expectedStatus: http.StatusOK,
+			expectedCalls:  1,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			calls.Store(0)
+			fail.Store(tC.fail)
+			var body io.Reader
+			if tC.body != "" {
+				body = strings.NewReader(tC.body)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(tC.method, tC.path, body))
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+			assert.Equal(t, tC.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestProxy_Timeouts(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{
+				Path:        "/request/",
+				Host:        slow.URL,
+				Timeouts:    config.Timeouts{Request: 20 * time.Millisecond},
+				Middlewares: []string{},
+			},
+			{
+				Path:        "/header/",
+				Host:        slow.URL,
+				Timeouts:    config.Timeouts{ResponseHeader: 20 * time.Millisecond},
+				Middlewares: []string{},
+			},
+		},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{})
+	require.NoError(t, err)
+	defer handler.Close()
+
+	testCases := []struct {
+		desc           string
+		path           string
+		expectedStatus int
+	}{
+		{desc: "Test request timeout", path: "/request/list", expectedStatus: http.StatusGatewayTimeout},
+		{desc: "Test response header timeout", path: "/header/list", expectedStatus: http.StatusGatewayTimeout},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			start := time.Now()
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tC.path, nil))
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+		})
+	}
+}
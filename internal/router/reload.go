@@ -0,0 +1,74 @@
+package router
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+)
+
+// BuildFunc builds the handler serving a config
+type BuildFunc func(cfg *config.Config) (http.Handler, error)
+
+// lifecycle is implemented by handlers running background work, such as
+// the health checks of a Router
+type lifecycle interface {
+	Start()
+	Close()
+}
+
+// Reloader serves requests with the most recently built routing table.
+// Reloads build a complete new table and swap it in atomically, so requests
+// in flight finish on the table they started on.
+type Reloader struct {
+	build   BuildFunc
+	mu      sync.Mutex // serializes reloads
+	handler atomic.Pointer[http.Handler]
+}
+
+// NewReloader builds the initial routing table for cfg
+func NewReloader(cfg *config.Config, build BuildFunc) (*Reloader, error) {
+	rl := &Reloader{build: build}
+	if err := rl.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// Reload validates cfg and swaps in a routing table built from it. On error
+// the current table keeps serving. The replaced table is closed before the
+// new one is started.
+func (rl *Reloader) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	handler, err := rl.build(cfg)
+	if err != nil {
+		return err
+	}
+	if old := rl.handler.Swap(&handler); old != nil {
+		if lc, ok := (*old).(lifecycle); ok {
+			lc.Close()
+		}
+	}
+	if lc, ok := handler.(lifecycle); ok {
+		lc.Start()
+	}
+	return nil
+}
+
+// Close closes the current routing table
+func (rl *Reloader) Close() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if lc, ok := (*rl.handler.Load()).(lifecycle); ok {
+		lc.Close()
+	}
+}
+
+func (rl *Reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*rl.handler.Load()).ServeHTTP(w, r)
+}
@@ -0,0 +1,100 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader(t *testing.T) {
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("orders"))
+	}))
+	defer orders.Close()
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	}))
+	defer users.Close()
+
+	build := func(cfg *config.Config) (http.Handler, error) {
+		return router.New(cfg, middlewares.Registry{})
+	}
+	handler, err := router.NewReloader(reloadConfig(
+		config.Route{Path: "/api/v1/orders/", Host: orders.URL, Middlewares: []string{}},
+	), build)
+	require.NoError(t, err)
+
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := get("/api/v1/orders/1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "orders", body)
+	code, _ = get("/api/v1/users/1")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	testCases := []struct {
+		desc  string
+		cfg   *config.Config
+		isErr bool
+	}{
+		{
+			desc: "Test invalid host keeps current routes",
+			cfg: reloadConfig(
+				config.Route{Path: "/api/v1/users/", Host: "users:8001", Middlewares: []string{}},
+			),
+			isErr: true,
+		},
+		{
+			desc: "Test unknown middleware keeps current routes",
+			cfg: reloadConfig(
+				config.Route{Path: "/api/v1/users/", Host: users.URL, Middlewares: []string{"missing"}},
+			),
+			isErr: true,
+		},
+		{
+			desc: "Test valid config swaps routes",
+			cfg: reloadConfig(
+				config.Route{Path: "/api/v1/orders/", Host: orders.URL, Middlewares: []string{}},
+				config.Route{Path: "/api/v1/users/", Host: users.URL, Middlewares: []string{}},
+			),
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := handler.Reload(tC.cfg)
+			if tC.isErr {
+				assert.Error(t, err)
+				code, _ := get("/api/v1/users/1")
+				assert.Equal(t, http.StatusNotFound, code)
+				return
+			}
+			assert.NoError(t, err)
+			code, body := get("/api/v1/users/1")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "users", body)
+		})
+	}
+
+	code, body = get("/api/v1/orders/1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "orders", body)
+}
+
+// reloadConfig returns a valid config serving routes
+func reloadConfig(routes ...config.Route) *config.Config {
+	cfg := &config.Config{Routes: routes}
+	cfg.Redis.Host = "localhost"
+	cfg.Redis.Port = "6379"
+	cfg.Security.APIKeyPepper = "pepper"
+	return cfg
+}
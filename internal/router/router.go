@@ -0,0 +1,139 @@
+package router
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/admin"
+	"github.com/arjunksofficial/tyk-task/internal/breaker"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/requestid"
+	"github.com/arjunksofficial/tyk-task/internal/ready"
+	"github.com/arjunksofficial/tyk-task/internal/rediscli"
+	"github.com/arjunksofficial/tyk-task/internal/tracing"
+	"github.com/arjunksofficial/tyk-task/internal/upstream"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Router is the routing table of a config
+type Router struct {
+	*mux.Router
+	// handler wraps the mux router in the middlewares every request goes
+	// through, including those no route matches
+	handler    http.Handler
+	pools      []*upstream.Pool
+	breakers   []*breaker.Breaker
+	transports []*http.Transport
+}
+
+// New builds the gateway router. Every configured route gets a reverse proxy
+// wrapped in the middleware chain it declares; the chains are built once here
+// rather than per request. Health checks only run once the router is
+// started.
+func New(cfg *config.Config, registry middlewares.Registry) (*Router, error) {
+	gw := &Router{Router: mux.NewRouter()}
+	router := gw.Router
+
+	router.HandleFunc("/health", healthCheckHandler).Methods("GET").Name("HealthCheck")
+	router.HandleFunc("/ready", ready.NewHandler(gw.upstreamStatus)).Methods("GET").Name("ReadyCheck")
+	router.Handle("/metrics", promhttp.Handler()).Name("Metrics")
+	accessLog, err := logging.NewAccessLogger(cfg.AccessLog, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	gw.handler = tracing.Middleware(requestid.New(cfg.RequestID.Header)(accessLog.LoggingMiddleware(router)))
+
+	if cfg.Admin.Secret != "" {
+		// The pepper of the config being built, which is not the current
+		// one yet while reloading
+		admin.NewHandler(cfg.Admin.Secret, rediscli.GetRedisClient(), cfg.Security.APIKeyPepper).Register(router)
+		log.Println("Admin API enabled under /admin")
+	}
+
+	for _, route := range cfg.GetRoutes() {
+		pool, err := upstream.NewPool(route)
+		if err != nil {
+			return nil, err
+		}
+		chain, err := registry.Build(route)
+		if err != nil {
+			return nil, err
+		}
+		transport, err := upstream.NewTransport(route)
+		if err != nil {
+			return nil, err
+		}
+		var proxy http.Handler = newProxy(route, pool, transport, requestid.Header(cfg.RequestID.Header))
+		if b := breaker.New(route); b != nil {
+			proxy = b.Wrap(proxy)
+			gw.breakers = append(gw.breakers, b)
+		}
+		router.PathPrefix(route.Path).Handler(chain(proxy)).Name(route.GetName())
+		gw.pools = append(gw.pools, pool)
+		gw.transports = append(gw.transports, transport)
+		log.Printf("Route registered: %s -> %s %v", route.Path, targetURLs(pool), route.GetMiddlewares())
+		if route.TLS.InsecureSkipVerify {
+			log.Printf("Route %s does not verify the certificates of its upstream", route.GetName())
+		}
+	}
+	return gw, nil
+}
+
+// Start starts the health checks of the routes' upstreams and publishes
+// the state of their circuit breakers
+func (gw *Router) Start() {
+	// Forget the routes of the routing table this one replaces
+	metrics.UpstreamTargetHealthy.Reset()
+	metrics.CircuitBreakerState.Reset()
+	for _, pool := range gw.pools {
+		pool.Start()
+	}
+	for _, b := range gw.breakers {
+		b.Publish()
+	}
+}
+
+// Close stops the health checks of the routes' upstreams and closes their
+// idle connections
+func (gw *Router) Close() {
+	for _, pool := range gw.pools {
+		pool.Close()
+	}
+	for _, transport := range gw.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// ServeHTTP serves r through the gateway's middlewares, which learn the
+// route r matches before the mux router dispatches it
+func (gw *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var match mux.RouteMatch
+	if gw.Router.Match(r, &match) && match.Route != nil {
+		r = metrics.WithRoute(r, match.Route.GetName())
+	}
+	gw.handler.ServeHTTP(w, r)
+}
+
+func (gw *Router) upstreamStatus() map[string]map[string]bool {
+	now := time.Now()
+	status := make(map[string]map[string]bool, len(gw.pools))
+	for _, pool := range gw.pools {
+		status[pool.Name()] = make(map[string]bool, len(pool.Targets()))
+		for _, target := range pool.Targets() {
+			status[pool.Name()][target.URL.String()] = target.Available(now)
+		}
+	}
+	return status
+}
+
+// Health check handler
+func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
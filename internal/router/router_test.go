@@ -0,0 +1,310 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/rediscli"
+	"github.com/arjunksofficial/tyk-task/internal/router"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream " + r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	deny := func(config.Route) (middlewares.Middleware, error) {
+		return func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			})
+		}, nil
+	}
+
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{Path: "/api/v1/public/", Host: upstream.URL, Middlewares: []string{}},
+			{Path: "/api/v1/private/", Host: upstream.URL, Middlewares: []string{"deny"}},
+		},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{"deny": deny})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			desc:           "Test public route is proxied",
+			path:           "/api/v1/public/list",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "upstream /api/v1/public/list",
+		},
+		{
+			desc:           "Test private route runs its chain",
+			path:           "/api/v1/private/list",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Unauthorized\n",
+		},
+		{
+			desc:           "Test chain is not accumulated across requests",
+			path:           "/api/v1/public/list",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "upstream /api/v1/public/list",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tC.path, nil))
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+			assert.Equal(t, tC.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestNew_RequestID(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Correlation-ID", r.Header.Get("X-Correlation-ID"))
+		w.Write([]byte(r.Header.Get("X-Correlation-ID")))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{Path: "/api/v1/orders/", Host: upstream.URL, Middlewares: []string{}},
+			{Path: "/api/v1/down/", Host: "http://127.0.0.1:1", Middlewares: []string{}},
+		},
+		RequestID: config.RequestID{Header: "X-Correlation-ID"},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{})
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/list", nil)
+	r.Header.Set("X-Correlation-ID", "corr-1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	assert.Equal(t, "corr-1", rr.Body.String(), "forwarded to the upstream")
+	assert.Equal(t, []string{"corr-1"}, rr.Header().Values("X-Correlation-ID"), "echoed by the upstream once")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/down/list", nil))
+	id := rr.Header().Get("X-Correlation-ID")
+	assert.NotEmpty(t, id)
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Equal(t, "Bad Gateway (request ID: "+id+")\n", rr.Body.String())
+}
+
+func TestNew_UnknownMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{Path: "/api/v1/orders/", Host: "http://localhost:8000", Middlewares: []string{"cors"}},
+		},
+	}
+	_, err := router.New(cfg, middlewares.Registry{})
+	assert.EqualError(t, err, `route /api/v1/orders/: unknown middleware "cors"`)
+}
+
+func TestNew_Targets(t *testing.T) {
+	var upstreams []string
+	for _, name := range []string{"orders-1", "orders-2"} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		defer server.Close()
+		upstreams = append(upstreams, server.URL)
+	}
+
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{
+				Path: "/api/v1/orders/",
+				Targets: []config.Target{
+					{URL: upstreams[0], Weight: 2},
+					{URL: upstreams[1]},
+				},
+				LoadBalancing: config.LoadBalancing{Strategy: "weighted"},
+				Middlewares:   []string{},
+			},
+		},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{})
+	assert.NoError(t, err)
+
+	var served []string
+	for range 6 {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/orders/list", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		served = append(served, rr.Body.String())
+	}
+	assert.Equal(t, []string{"orders-1", "orders-2", "orders-1", "orders-1", "orders-2", "orders-1"}, served)
+}
+
+func TestNew_UnknownStrategy(t *testing.T) {
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{
+				Path:          "/api/v1/orders/",
+				Host:          "http://localhost:8000",
+				LoadBalancing: config.LoadBalancing{Strategy: "random"},
+			},
+		},
+	}
+	_, err := router.New(cfg, middlewares.Registry{})
+	assert.EqualError(t, err, `route /api/v1/orders/: unknown load balancing strategy "random"`)
+}
+
+func TestNew_PassiveHealthCheck(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer working.Close()
+
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{
+				Path:    "/api/v1/orders/",
+				Targets: []config.Target{{URL: failing.URL}, {URL: working.URL}},
+				HealthCheck: config.HealthCheck{Passive: config.PassiveHealthCheck{
+					UnhealthyThreshold: 1,
+					EjectionDuration:   time.Minute,
+				}},
+				Middlewares: []string{},
+			},
+		},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{})
+	assert.NoError(t, err)
+	handler.Start()
+	defer handler.Close()
+
+	var statuses []int
+	for range 4 {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/orders/list", nil))
+		statuses = append(statuses, rr.Code)
+	}
+	assert.Equal(t, []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK, http.StatusOK}, statuses)
+}
+
+func TestNew_Metrics(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{Name: "metrics-users", Path: "/api/v1/users/", Host: upstream.URL, Middlewares: []string{}},
+		},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{})
+	require.NoError(t, err)
+
+	for _, path := range []string{"/api/v1/users/123", "/api/v1/users/456"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.HttpRequestsTotal.WithLabelValues("metrics-users", "POST", "2xx", upstream.URL)),
+		"requests are labelled by route name, not path")
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.HttpRequestsInFlight.WithLabelValues("metrics-users")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.UpstreamRequestsInFlight.WithLabelValues("metrics-users", upstream.URL)))
+
+	var m dto.Metric
+	require.NoError(t, metrics.UpstreamRequestDuration.WithLabelValues("metrics-users", upstream.URL, "2xx").(prometheus.Histogram).Write(&m))
+	assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+}
+
+func TestNew_UnmatchedRequests(t *testing.T) {
+	cfg := &config.Config{
+		Routes: []config.Route{
+			{Path: "/api/v1/users/", Host: "http://localhost:8000", Middlewares: []string{}},
+		},
+	}
+	handler, err := router.New(cfg, middlewares.Registry{})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{
+			desc:           "Test unknown path gets a request ID and is counted",
+			method:         http.MethodDelete,
+			path:           "/api/v2/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "Test wrong method gets a request ID and is counted",
+			method:         http.MethodDelete,
+			path:           "/health",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			total := metrics.HttpRequestsTotal.WithLabelValues("", "DELETE", "4xx", "")
+			before := testutil.ToFloat64(total)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(tC.method, tC.path, nil))
+
+			assert.Equal(t, tC.expectedStatus, rr.Code)
+			assert.NotEmpty(t, rr.Header().Get("X-Request-ID"))
+			assert.Equal(t, before+1, testutil.ToFloat64(total))
+		})
+	}
+}
+
+func TestNew_AdminUsesBuiltConfigPepper(t *testing.T) {
+	mr := miniredis.RunT(t)
+	current := &config.Config{}
+	current.Redis.Host, current.Redis.Port = mr.Host(), mr.Port()
+	current.Security.APIKeyPepper = "old-pepper"
+	config.SetConfig(current)
+	defer rediscli.CloseRedisClient()
+
+	// The router of a reload is built before its config becomes current
+	cfg := &config.Config{}
+	cfg.Security.APIKeyPepper = "new-pepper"
+	cfg.Admin.Secret = "s3cret"
+	handler, err := router.New(cfg, middlewares.Registry{})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/tokens", strings.NewReader(`{"rate_limit": 10}`))
+	r.Header.Set("Authorization", "Bearer s3cret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var token models.TokenData
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &token))
+	assert.Equal(t, []string{"token:" + services.HashAPIKey("new-pepper", token.APIKey)}, mr.Keys())
+}
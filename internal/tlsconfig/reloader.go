@@ -0,0 +1,104 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// CertReloader serves a certificate and reloads it when its files change.
+// The directories of the files are watched rather than the files, so
+// renames into place and Kubernetes secret updates, which swap a symlink,
+// are noticed. A certificate failing to load, e.g. because only the cert
+// has been written so far, leaves the current one in use.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	watcher  *fsnotify.Watcher
+	wg       sync.WaitGroup
+}
+
+// NewCertReloader loads the certificate and starts watching its files
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watching certificate: %w", err)
+	}
+	for _, dir := range []string{filepath.Dir(certFile), filepath.Dir(keyFile)} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("watching certificate: %w", err)
+		}
+	}
+	c.watcher = watcher
+	c.wg.Add(1)
+	go c.watch()
+	return c, nil
+}
+
+// Reload loads the certificate from its files
+func (c *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	c.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate returns the current certificate, for use as
+// tls.Config.GetCertificate
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+// Close stops watching the certificate files
+func (c *CertReloader) Close() error {
+	err := c.watcher.Close()
+	c.wg.Wait()
+	return err
+}
+
+// affects reports whether a change of file can change the certificate:
+// the files themselves or the ..data symlink of a Kubernetes secret
+func (c *CertReloader) affects(file string) bool {
+	return file == filepath.Clean(c.certFile) || file == filepath.Clean(c.keyFile) ||
+		strings.HasPrefix(filepath.Base(file), "..")
+}
+
+func (c *CertReloader) watch() {
+	defer c.wg.Done()
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) || !c.affects(event.Name) {
+				continue
+			}
+			if err := c.Reload(); err != nil {
+				log.Printf("Certificate reload failed, keeping current certificate: %v", err)
+				continue
+			}
+			log.Printf("Certificate reloaded from %s", c.certFile)
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watching certificate failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+)
+
+// NewServer creates the TLS config of the HTTPS listener. Its certificate
+// is served by a CertReloader, which the caller closes on shutdown.
+func NewServer(cfg config.TLS) (*tls.Config, *CertReloader, error) {
+	version, err := cfg.Version()
+	if err != nil {
+		return nil, nil, err
+	}
+	suites, err := cfg.CipherSuiteIDs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:   version,
+		CipherSuites: suites,
+	}
+	if cfg.ClientCAFile != "" {
+		pool, err := LoadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Only mtls routes require a certificate, so others stay reachable
+		// without one
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	certs, err := NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsCfg.GetCertificate = certs.GetCertificate
+	return tlsCfg, certs, nil
+}
+
+// NewUpstream creates the TLS config of a route's transport, or returns nil
+// when the route keeps the defaults
+func NewUpstream(cfg config.UpstreamTLS) (*tls.Config, error) {
+	if cfg == (config.UpstreamTLS{}) {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pool, err := LoadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// RedirectHandler redirects plain HTTP requests to the HTTPS listener on
+// httpsPort, keeping the method and body with a 308
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,180 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/tlsconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for localhost with the serial
+// number to dir and returns the paths of the cert and key
+func writeCert(t *testing.T, dir string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return certFile, keyFile
+}
+
+func serial(t *testing.T, c *tlsconfig.CertReloader) int64 {
+	t.Helper()
+	cert, err := c.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, 1)
+
+	certs, err := tlsconfig.NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	defer certs.Close()
+	assert.Equal(t, int64(1), serial(t, certs))
+
+	writeCert(t, dir, 2)
+	assert.Eventually(t, func() bool { return serial(t, certs) == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	// A broken certificate keeps the current one
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(2), serial(t, certs))
+	assert.Error(t, certs.Reload())
+}
+
+func TestNewCertReloader_MissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := tlsconfig.NewCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	assert.Error(t, err)
+}
+
+func TestNewServer(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, 1)
+	roots, err := tlsconfig.LoadCertPool(certFile)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc       string
+		cfg        config.TLS
+		maxVersion uint16
+		clientCert bool
+		isErr      bool
+		verified   bool
+	}{
+		{desc: "Test TLS 1.2 by default", cfg: config.TLS{}, maxVersion: tls.VersionTLS12},
+		{desc: "Test TLS 1.2 refused", cfg: config.TLS{MinVersion: "1.3"}, maxVersion: tls.VersionTLS12, isErr: true},
+		{desc: "Test TLS 1.3", cfg: config.TLS{MinVersion: "1.3"}, maxVersion: tls.VersionTLS13},
+		{
+			desc:       "Test client certificate verified",
+			cfg:        config.TLS{ClientCAFile: certFile},
+			maxVersion: tls.VersionTLS13,
+			clientCert: true,
+			verified:   true,
+		},
+		{
+			desc:       "Test client certificate optional",
+			cfg:        config.TLS{ClientCAFile: certFile},
+			maxVersion: tls.VersionTLS13,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			tC.cfg.CertFile, tC.cfg.KeyFile = certFile, keyFile
+			tlsCfg, certs, err := tlsconfig.NewServer(tC.cfg)
+			require.NoError(t, err)
+			defer certs.Close()
+
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(r.TLS.VerifiedChains) > 0 {
+					w.Header().Set("X-Verified", "true")
+				}
+			}))
+			srv.TLS = tlsCfg
+			srv.StartTLS()
+			defer srv.Close()
+
+			clientCfg := &tls.Config{RootCAs: roots, MaxVersion: tC.maxVersion, ServerName: "localhost"}
+			if tC.clientCert {
+				cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+				require.NoError(t, err)
+				clientCfg.Certificates = []tls.Certificate{cert}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientCfg}}
+			resp, err := client.Get(srv.URL)
+			if tC.isErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tC.verified, resp.Header.Get("X-Verified") == "true")
+		})
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		httpsPort string
+		target    string
+		expected  string
+	}{
+		{
+			desc:      "Test redirect to https port",
+			httpsPort: "9443",
+			target:    "http://gateway.example.com:8080/api/v1/orders/?page=2",
+			expected:  "https://gateway.example.com:9443/api/v1/orders/?page=2",
+		},
+		{
+			desc:      "Test default https port is left out",
+			httpsPort: "443",
+			target:    "http://gateway.example.com/api/v1/orders/",
+			expected:  "https://gateway.example.com/api/v1/orders/",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tlsconfig.RedirectHandler(tC.httpsPort).ServeHTTP(w, httptest.NewRequest(http.MethodPost, tC.target, nil))
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tC.expected, w.Header().Get("Location"))
+		})
+	}
+}
@@ -6,10 +6,27 @@ import (
 )
 
 type TokenData struct {
-	APIKey        string   `json:"api_key" yaml:"api_key"`
+	// APIKey is only known when the token is created; Redis holds KeyHash
+	APIKey        string   `json:"api_key,omitempty" yaml:"api_key"`
+	KeyHash       string   `json:"key_hash,omitempty" yaml:"key_hash"`
 	RateLimit     int      `json:"rate_limit" yaml:"rate_limit"`
 	ExpiresAt     string   `json:"expires_at" yaml:"expires_at"`
 	AllowedRoutes []string `json:"allowed_routes" yaml:"allowed_routes"`
+	// RateLimitAlgorithm overrides the route's rate limiting algorithm
+	RateLimitAlgorithm string `json:"rate_limit_algorithm,omitempty" yaml:"rate_limit_algorithm"`
+	// BurstCapacity and RefillRate (tokens per second) configure the token
+	// bucket, e.g. bursts of 50 while sustaining 5 requests per second
+	BurstCapacity int     `json:"burst_capacity,omitempty" yaml:"burst_capacity"`
+	RefillRate    float64 `json:"refill_rate,omitempty" yaml:"refill_rate"`
+}
+
+// RateLimitStatus is the state of a rate limit window after a request
+type RateLimitStatus struct {
+	Allowed   bool
+	Count     int64
+	Limit     int64
+	Remaining int64
+	ResetAt   time.Time
 }
 
 type ContextKey string
@@ -18,6 +35,15 @@ const (
 	TokenContextKey ContextKey = "token"
 )
 
+// RateLimitKey identifies the token in rate limit counters without exposing
+// the plaintext API key
+func (t *TokenData) RateLimitKey() string {
+	if t.KeyHash != "" {
+		return t.KeyHash
+	}
+	return t.APIKey
+}
+
 func NewToken(apiKey string) *TokenData {
 	return &TokenData{
 		APIKey:        apiKey,
@@ -36,6 +62,12 @@ func (t *TokenData) SetRateLimit(limit int) {
 	t.RateLimit = limit
 }
 
+// SetBucket sets the token bucket capacity and refill rate per second
+func (t *TokenData) SetBucket(capacity int, refillRate float64) {
+	t.BurstCapacity = capacity
+	t.RefillRate = refillRate
+}
+
 // SetAllowedRoutes sets the allowed routes for the token
 func (t *TokenData) SetAllowedRoutes(routes []string) {
 	t.AllowedRoutes = routes
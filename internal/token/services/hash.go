@@ -0,0 +1,21 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashAPIKey returns the HMAC-SHA256 of the API key keyed with the server
+// side pepper. Only this hash is stored in Redis, so read access to Redis
+// is not enough to impersonate a client.
+func HashAPIKey(pepper, apiKey string) string {
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(apiKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// tokenKey is the Redis key holding the token with the given hash
+func tokenKey(keyHash string) string {
+	return "token:" + keyHash
+}
@@ -6,6 +6,7 @@ package services
 
 import (
 	"context"
+	"time"
 
 	"github.com/arjunksofficial/tyk-task/internal/token/models"
 	mock "github.com/stretchr/testify/mock"
@@ -38,6 +39,84 @@ func (_m *MockService) EXPECT() *MockService_Expecter {
 	return &MockService_Expecter{mock: &_m.Mock}
 }
 
+// ConsumeRateLimit provides a mock function for the type MockService
+func (_mock *MockService) ConsumeRateLimit(ctx context.Context, token string, limit int64, window time.Duration) (models.RateLimitStatus, error) {
+	ret := _mock.Called(ctx, token, limit, window)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeRateLimit")
+	}
+
+	var r0 models.RateLimitStatus
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64, time.Duration) (models.RateLimitStatus, error)); ok {
+		return returnFunc(ctx, token, limit, window)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64, time.Duration) models.RateLimitStatus); ok {
+		r0 = returnFunc(ctx, token, limit, window)
+	} else {
+		r0 = ret.Get(0).(models.RateLimitStatus)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int64, time.Duration) error); ok {
+		r1 = returnFunc(ctx, token, limit, window)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockService_ConsumeRateLimit_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ConsumeRateLimit'
+type MockService_ConsumeRateLimit_Call struct {
+	*mock.Call
+}
+
+// ConsumeRateLimit is a helper method to define mock.On call
+//   - ctx context.Context
+//   - token string
+//   - limit int64
+//   - window time.Duration
+func (_e *MockService_Expecter) ConsumeRateLimit(ctx interface{}, token interface{}, limit interface{}, window interface{}) *MockService_ConsumeRateLimit_Call {
+	return &MockService_ConsumeRateLimit_Call{Call: _e.mock.On("ConsumeRateLimit", ctx, token, limit, window)}
+}
+
+func (_c *MockService_ConsumeRateLimit_Call) Run(run func(ctx context.Context, token string, limit int64, window time.Duration)) *MockService_ConsumeRateLimit_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int64
+		if args[2] != nil {
+			arg2 = args[2].(int64)
+		}
+		var arg3 time.Duration
+		if args[3] != nil {
+			arg3 = args[3].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3,
+		)
+	})
+	return _c
+}
+
+func (_c *MockService_ConsumeRateLimit_Call) Return(rateLimitStatus models.RateLimitStatus, err error) *MockService_ConsumeRateLimit_Call {
+	_c.Call.Return(rateLimitStatus, err)
+	return _c
+}
+
+func (_c *MockService_ConsumeRateLimit_Call) RunAndReturn(run func(ctx context.Context, token string, limit int64, window time.Duration) (models.RateLimitStatus, error)) *MockService_ConsumeRateLimit_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DeleteToken provides a mock function for the type MockService
 func (_mock *MockService) DeleteToken(ctx context.Context, token string) error {
 	ret := _mock.Called(ctx, token)
@@ -95,6 +174,63 @@ func (_c *MockService_DeleteToken_Call) RunAndReturn(run func(ctx context.Contex
 	return _c
 }
 
+// DeleteTokenByHash provides a mock function for the type MockService
+func (_mock *MockService) DeleteTokenByHash(ctx context.Context, keyHash string) error {
+	ret := _mock.Called(ctx, keyHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteTokenByHash")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, keyHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockService_DeleteTokenByHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteTokenByHash'
+type MockService_DeleteTokenByHash_Call struct {
+	*mock.Call
+}
+
+// DeleteTokenByHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyHash string
+func (_e *MockService_Expecter) DeleteTokenByHash(ctx interface{}, keyHash interface{}) *MockService_DeleteTokenByHash_Call {
+	return &MockService_DeleteTokenByHash_Call{Call: _e.mock.On("DeleteTokenByHash", ctx, keyHash)}
+}
+
+func (_c *MockService_DeleteTokenByHash_Call) Run(run func(ctx context.Context, keyHash string)) *MockService_DeleteTokenByHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockService_DeleteTokenByHash_Call) Return(err error) *MockService_DeleteTokenByHash_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockService_DeleteTokenByHash_Call) RunAndReturn(run func(ctx context.Context, keyHash string) error) *MockService_DeleteTokenByHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetToken provides a mock function for the type MockService
 func (_mock *MockService) GetToken(ctx context.Context, token string) (models.TokenData, error) {
 	ret := _mock.Called(ctx, token)
@@ -161,6 +297,72 @@ func (_c *MockService_GetToken_Call) RunAndReturn(run func(ctx context.Context,
 	return _c
 }
 
+// GetTokenByHash provides a mock function for the type MockService
+func (_mock *MockService) GetTokenByHash(ctx context.Context, keyHash string) (models.TokenData, error) {
+	ret := _mock.Called(ctx, keyHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTokenByHash")
+	}
+
+	var r0 models.TokenData
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (models.TokenData, error)); ok {
+		return returnFunc(ctx, keyHash)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) models.TokenData); ok {
+		r0 = returnFunc(ctx, keyHash)
+	} else {
+		r0 = ret.Get(0).(models.TokenData)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, keyHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockService_GetTokenByHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetTokenByHash'
+type MockService_GetTokenByHash_Call struct {
+	*mock.Call
+}
+
+// GetTokenByHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyHash string
+func (_e *MockService_Expecter) GetTokenByHash(ctx interface{}, keyHash interface{}) *MockService_GetTokenByHash_Call {
+	return &MockService_GetTokenByHash_Call{Call: _e.mock.On("GetTokenByHash", ctx, keyHash)}
+}
+
+func (_c *MockService_GetTokenByHash_Call) Run(run func(ctx context.Context, keyHash string)) *MockService_GetTokenByHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockService_GetTokenByHash_Call) Return(tokenData models.TokenData, err error) *MockService_GetTokenByHash_Call {
+	_c.Call.Return(tokenData, err)
+	return _c
+}
+
+func (_c *MockService_GetTokenByHash_Call) RunAndReturn(run func(ctx context.Context, keyHash string) (models.TokenData, error)) *MockService_GetTokenByHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // IncrementRateLimit provides a mock function for the type MockService
 func (_mock *MockService) IncrementRateLimit(ctx context.Context, token string) (int64, error) {
 	ret := _mock.Called(ctx, token)
@@ -227,6 +429,128 @@ func (_c *MockService_IncrementRateLimit_Call) RunAndReturn(run func(ctx context
 	return _c
 }
 
+// ListTokens provides a mock function for the type MockService
+func (_mock *MockService) ListTokens(ctx context.Context) ([]models.TokenData, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTokens")
+	}
+
+	var r0 []models.TokenData
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) ([]models.TokenData, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) []models.TokenData); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.TokenData)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockService_ListTokens_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListTokens'
+type MockService_ListTokens_Call struct {
+	*mock.Call
+}
+
+// ListTokens is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockService_Expecter) ListTokens(ctx interface{}) *MockService_ListTokens_Call {
+	return &MockService_ListTokens_Call{Call: _e.mock.On("ListTokens", ctx)}
+}
+
+func (_c *MockService_ListTokens_Call) Run(run func(ctx context.Context)) *MockService_ListTokens_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockService_ListTokens_Call) Return(tokenDatas []models.TokenData, err error) *MockService_ListTokens_Call {
+	_c.Call.Return(tokenDatas, err)
+	return _c
+}
+
+func (_c *MockService_ListTokens_Call) RunAndReturn(run func(ctx context.Context) ([]models.TokenData, error)) *MockService_ListTokens_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MigratePlaintextTokens provides a mock function for the type MockService
+func (_mock *MockService) MigratePlaintextTokens(ctx context.Context) (int, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MigratePlaintextTokens")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (int, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockService_MigratePlaintextTokens_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MigratePlaintextTokens'
+type MockService_MigratePlaintextTokens_Call struct {
+	*mock.Call
+}
+
+// MigratePlaintextTokens is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockService_Expecter) MigratePlaintextTokens(ctx interface{}) *MockService_MigratePlaintextTokens_Call {
+	return &MockService_MigratePlaintextTokens_Call{Call: _e.mock.On("MigratePlaintextTokens", ctx)}
+}
+
+func (_c *MockService_MigratePlaintextTokens_Call) Run(run func(ctx context.Context)) *MockService_MigratePlaintextTokens_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockService_MigratePlaintextTokens_Call) Return(n int, err error) *MockService_MigratePlaintextTokens_Call {
+	_c.Call.Return(n, err)
+	return _c
+}
+
+func (_c *MockService_MigratePlaintextTokens_Call) RunAndReturn(run func(ctx context.Context) (int, error)) *MockService_MigratePlaintextTokens_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // StoreToken provides a mock function for the type MockService
 func (_mock *MockService) StoreToken(ctx context.Context, token models.TokenData) error {
 	ret := _mock.Called(ctx, token)
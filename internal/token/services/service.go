@@ -3,32 +3,61 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/arjunksofficial/tyk-task/internal/config"
 	"github.com/arjunksofficial/tyk-task/internal/rediscli"
 	"github.com/arjunksofficial/tyk-task/internal/token/models"
 	"github.com/redis/go-redis/v9"
 )
 
+// Service stores tokens by the hash of their API key. GetToken and
+// DeleteToken take the plaintext key presented by the client, their ByHash
+// variants the KeyHash returned by ListTokens; StoreToken hashes the token's
+// APIKey, or reuses its KeyHash when the key is unknown.
 type Service interface {
 	GetToken(ctx context.Context, token string) (models.TokenData, error)
+	GetTokenByHash(ctx context.Context, keyHash string) (models.TokenData, error)
 	StoreToken(ctx context.Context, token models.TokenData) error
 	DeleteToken(ctx context.Context, token string) error
+	DeleteTokenByHash(ctx context.Context, keyHash string) error
+	ListTokens(ctx context.Context) ([]models.TokenData, error)
+	// MigratePlaintextTokens rewrites tokens stored under their plaintext key
+	// and returns how many were migrated
+	MigratePlaintextTokens(ctx context.Context) (int, error)
 
 	IncrementRateLimit(ctx context.Context, token string) (int64, error)
+	// ConsumeRateLimit counts a request against the fixed window of the given
+	// length and reports the remaining quota and when the window resets
+	ConsumeRateLimit(ctx context.Context, token string, limit int64, window time.Duration) (models.RateLimitStatus, error)
 }
 
 type service struct {
 	redisClient *redis.Client
+	pepper      string
 }
 
 func New() Service {
+	return NewWithClient(rediscli.GetRedisClient(), config.GetConfig().Security.APIKeyPepper)
+}
+
+// NewWithClient creates a Service using the given Redis client and the pepper
+// API keys are hashed with
+func NewWithClient(redisClient *redis.Client, pepper string) Service {
 	return &service{
-		redisClient: rediscli.GetRedisClient(),
+		redisClient: redisClient,
+		pepper:      pepper,
 	}
 }
+
 func (s *service) GetToken(ctx context.Context, token string) (models.TokenData, error) {
-	key := "token:" + token
+	return s.GetTokenByHash(ctx, HashAPIKey(s.pepper, token))
+}
+
+func (s *service) GetTokenByHash(ctx context.Context, keyHash string) (models.TokenData, error) {
+	key := tokenKey(keyHash)
 
 	// Fetch the token data from Redis
 	data, err := s.redisClient.Get(ctx, key).Result()
@@ -48,7 +77,14 @@ func (s *service) GetToken(ctx context.Context, token string) (models.TokenData,
 }
 
 func (s *service) StoreToken(ctx context.Context, token models.TokenData) error {
-	key := "token:" + token.APIKey
+	if token.APIKey != "" {
+		token.KeyHash = HashAPIKey(s.pepper, token.APIKey)
+		token.APIKey = "" // never persist the plaintext key
+	}
+	if token.KeyHash == "" {
+		return errors.New("token has neither API key nor key hash")
+	}
+	key := tokenKey(token.KeyHash)
 	data, err := json.Marshal(token)
 	if err != nil {
 		return err
@@ -59,26 +95,116 @@ func (s *service) StoreToken(ctx context.Context, token models.TokenData) error
 }
 
 func (s *service) DeleteToken(ctx context.Context, token string) error {
-	key := "token:" + token
+	return s.DeleteTokenByHash(ctx, HashAPIKey(s.pepper, token))
+}
+
+func (s *service) DeleteTokenByHash(ctx context.Context, keyHash string) error {
 	// Delete the token data from Redis
-	return s.redisClient.Del(ctx, key).Err()
+	return s.redisClient.Del(ctx, tokenKey(keyHash)).Err()
 }
 
-func (s *service) IncrementRateLimit(ctx context.Context, token string) (int64, error) {
-	// Fixed window key: token:<api_key>:rate:<YYYYMMDDHHMM>
-	window := time.Now().UTC().Format("200601021504")
-	key := "rate_limit:" + token + ":" + window
+func (s *service) ListTokens(ctx context.Context) ([]models.TokenData, error) {
+	tokens := []models.TokenData{}
+	// SCAN rather than KEYS so that listing does not block Redis
+	iter := s.redisClient.Scan(ctx, 0, "token:*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := s.redisClient.Get(ctx, iter.Val()).Result()
+		if err == redis.Nil {
+			continue // deleted since the scan returned it
+		}
+		if err != nil {
+			return nil, err
+		}
+		var tokenData models.TokenData
+		if err := json.Unmarshal([]byte(data), &tokenData); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tokenData)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *service) MigratePlaintextTokens(ctx context.Context) (int, error) {
+	migrated := 0
+	iter := s.redisClient.Scan(ctx, 0, "token:*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		data, err := s.redisClient.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return migrated, err
+		}
+		var tokenData models.TokenData
+		if err := json.Unmarshal([]byte(data), &tokenData); err != nil {
+			return migrated, fmt.Errorf("%s: %w", key, err)
+		}
+		// Hashed entries carry no API key in their payload
+		if tokenData.APIKey == "" || key != tokenKey(tokenData.APIKey) {
+			continue
+		}
+		if err := s.StoreToken(ctx, tokenData); err != nil {
+			return migrated, err
+		}
+		if err := s.redisClient.Del(ctx, key).Err(); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, iter.Err()
+}
+
+// rateLimitScript increments the window counter and sets it to expire at
+// the end of the window in one step, so a crash between the two can no
+// longer leave a counter that never expires. A counter found without TTL is
+// given one again. Windows are aligned to the epoch on the Redis clock, so
+// gateway replicas with skewed clocks share them.
+// Returns {count, remaining, allowed, reset_ms}; a negative limit only counts.
+var rateLimitScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[2])
+local reset = window - now % window
+
+local count = redis.call('INCR', KEYS[1])
+if count == 1 or redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], reset)
+end
+
+local limit = tonumber(ARGV[1])
+local remaining = math.max(limit - count, 0)
+local allowed = 0
+if limit < 0 or count <= limit then
+	allowed = 1
+end
+return {count, remaining, allowed, reset}
+`)
 
-	// Increment the rate limit counter
-	count, err := s.redisClient.Incr(ctx, key).Result()
+func (s *service) IncrementRateLimit(ctx context.Context, token string) (int64, error) {
+	status, err := s.ConsumeRateLimit(ctx, token, -1, time.Minute)
 	if err != nil {
 		return 0, err
 	}
+	return status.Count, nil
+}
 
-	// Set an expiration time for the rate limit key if it is the first increment
-	if count == 1 {
-		s.redisClient.Expire(ctx, key, time.Minute) // 1 minute
+func (s *service) ConsumeRateLimit(ctx context.Context, token string, limit int64, window time.Duration) (models.RateLimitStatus, error) {
+	// Fixed window key: rate_limit:<api_key>. It expires when its window
+	// ends, so the next request starts a new window.
+	key := "rate_limit:" + token
+	res, err := rateLimitScript.Run(ctx, s.redisClient, []string{key}, limit, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return models.RateLimitStatus{}, err
 	}
-
-	return count, nil
+	return models.RateLimitStatus{
+		Allowed:   res[2] == 1,
+		Count:     res[0],
+		Limit:     limit,
+		Remaining: res[1],
+		ResetAt:   time.Now().Add(time.Duration(res[3]) * time.Millisecond),
+	}, nil
 }
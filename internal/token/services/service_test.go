@@ -0,0 +1,156 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/token/services"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_ConsumeRateLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	// Windows follow the Redis clock, however far the gateway's is off
+	mr.SetTime(time.Date(2030, 1, 1, 0, 40, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	svc := services.NewWithClient(client, "pepper")
+	ctx := context.Background()
+
+	testCases := []struct {
+		desc              string
+		expectedAllowed   bool
+		expectedCount     int64
+		expectedRemaining int64
+	}{
+		{desc: "Test first request", expectedAllowed: true, expectedCount: 1, expectedRemaining: 1},
+		{desc: "Test last request within limit", expectedAllowed: true, expectedCount: 2, expectedRemaining: 0},
+		{desc: "Test request over limit", expectedAllowed: false, expectedCount: 3, expectedRemaining: 0},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			status, err := svc.ConsumeRateLimit(ctx, "api_key", 2, time.Hour)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expectedAllowed, status.Allowed)
+			assert.Equal(t, tC.expectedCount, status.Count)
+			assert.Equal(t, int64(2), status.Limit)
+			assert.Equal(t, tC.expectedRemaining, status.Remaining)
+			// the window ends at the next full hour on the Redis clock, not
+			// an hour after the first request
+			assert.WithinDuration(t, time.Now().Add(20*time.Minute), status.ResetAt, time.Second)
+		})
+	}
+
+	assert.Equal(t, []string{"rate_limit:api_key"}, mr.Keys())
+	assert.Equal(t, 20*time.Minute, mr.TTL("rate_limit:api_key"))
+
+	// The next window starts from scratch
+	mr.FastForward(20 * time.Minute)
+	mr.SetTime(time.Date(2030, 1, 1, 1, 0, 0, 0, time.UTC))
+	status, err := svc.ConsumeRateLimit(ctx, "api_key", 2, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), status.Count)
+	assert.Equal(t, time.Hour, mr.TTL("rate_limit:api_key"))
+}
+
+func TestService_ConsumeRateLimit_RestoresMissingTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	svc := services.NewWithClient(client, "pepper")
+
+	// A counter left behind by a crash between INCR and EXPIRE
+	_, err := svc.IncrementRateLimit(context.Background(), "api_key")
+	assert.NoError(t, err)
+	for _, key := range mr.Keys() {
+		client.Persist(context.Background(), key)
+	}
+
+	count, err := svc.IncrementRateLimit(context.Background(), "api_key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	for _, key := range mr.Keys() {
+		assert.Greater(t, mr.TTL(key), time.Duration(0))
+		assert.LessOrEqual(t, mr.TTL(key), time.Minute)
+	}
+}
+
+func TestService_StoreToken_HashesKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	svc := services.NewWithClient(client, "pepper")
+	ctx := context.Background()
+
+	token := models.TokenData{APIKey: "plaintext_api_key", RateLimit: 5, AllowedRoutes: []string{"/api/v1/users/*"}}
+	assert.NoError(t, svc.StoreToken(ctx, token))
+
+	keyHash := services.HashAPIKey("pepper", "plaintext_api_key")
+	assert.Equal(t, []string{"token:" + keyHash}, mr.Keys())
+	stored, err := mr.Get("token:" + keyHash)
+	assert.NoError(t, err)
+	assert.NotContains(t, stored, "plaintext_api_key")
+
+	got, err := svc.GetToken(ctx, "plaintext_api_key")
+	assert.NoError(t, err)
+	assert.Equal(t, keyHash, got.KeyHash)
+	assert.Empty(t, got.APIKey)
+	assert.Equal(t, 5, got.RateLimit)
+
+	// A different pepper yields a different hash, so the key is not found
+	_, err = services.NewWithClient(client, "other").GetToken(ctx, "plaintext_api_key")
+	assert.Equal(t, redis.Nil, err)
+
+	// Updating a fetched token keeps it under the same hash
+	got.RateLimit = 10
+	assert.NoError(t, svc.StoreToken(ctx, got))
+	assert.Equal(t, []string{"token:" + keyHash}, mr.Keys())
+
+	byHash, err := svc.GetTokenByHash(ctx, keyHash)
+	assert.NoError(t, err)
+	assert.Equal(t, got, byHash)
+
+	assert.NoError(t, svc.DeleteToken(ctx, "plaintext_api_key"))
+	assert.Empty(t, mr.Keys())
+
+	assert.NoError(t, svc.StoreToken(ctx, got))
+	assert.NoError(t, svc.DeleteTokenByHash(ctx, keyHash))
+	assert.Empty(t, mr.Keys())
+}
+
+func TestService_MigratePlaintextTokens(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	svc := services.NewWithClient(client, "pepper")
+	ctx := context.Background()
+
+	// Entries written by the previous version of StoreToken
+	for _, key := range []string{"legacy_one", "legacy_two"} {
+		data, _ := json.Marshal(models.TokenData{APIKey: key, RateLimit: 5})
+		assert.NoError(t, mr.Set("token:"+key, string(data)))
+	}
+	assert.NoError(t, svc.StoreToken(ctx, models.TokenData{APIKey: "already_hashed", RateLimit: 5}))
+
+	migrated, err := svc.MigratePlaintextTokens(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+
+	for _, key := range []string{"legacy_one", "legacy_two", "already_hashed"} {
+		assert.False(t, mr.Exists("token:"+key))
+		token, err := svc.GetToken(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, token.RateLimit)
+	}
+
+	migrated, err = svc.MigratePlaintextTokens(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+}
@@ -0,0 +1,131 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/auth"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/ratelimit"
+	"github.com/arjunksofficial/tyk-task/internal/router"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an OTLP/HTTP trace collector keeping the spans it receives
+type collector struct {
+	mu       sync.Mutex
+	services []string
+	spans    map[string]*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, attr := range rs.Resource.Attributes {
+			if attr.Key == "service.name" {
+				c.services = append(c.services, attr.Value.GetStringValue())
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				c.spans[span.Name] = span
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	body, _ = proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Write(body)
+}
+
+type authenticatorFunc func(r *http.Request) (models.TokenData, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (models.TokenData, error) {
+	return f(r)
+}
+
+func TestInit(t *testing.T) {
+	stub := &collector{spans: map[string]*tracepb.Span{}}
+	otlp := httptest.NewServer(stub)
+	defer otlp.Close()
+
+	flush, err := tracing.Init(context.Background(), config.Tracing{Endpoint: otlp.URL, ServiceName: "apigw-test"})
+	require.NoError(t, err)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	limiter := ratelimit.NewMockLimiter(t)
+	limiter.EXPECT().Allow(mock.Anything, "key-hash", mock.Anything).Return(ratelimit.Result{Allowed: true, Limit: 10, Remaining: 9}, nil)
+	registry := middlewares.Registry{
+		"auth": func(config.Route) (middlewares.Middleware, error) {
+			a := &auth.AuthMiddleware{Authenticator: authenticatorFunc(func(r *http.Request) (models.TokenData, error) {
+				return models.TokenData{KeyHash: "key-hash", RateLimit: 10, AllowedRoutes: []string{"/api/v1/orders/*"}}, nil
+			})}
+			return a.AuthMiddleware, nil
+		},
+		"ratelimit": func(config.Route) (middlewares.Middleware, error) {
+			rl := &ratelimit.RateLimitMiddleware{Limiters: map[string]ratelimit.Limiter{ratelimit.FixedWindow: limiter}}
+			return rl.RateLimitHandler, nil
+		},
+	}
+	handler, err := router.New(&config.Config{Routes: []config.Route{
+		{Name: "orders", Path: "/api/v1/orders/", Host: upstream.URL, Middlewares: []string{"auth", "ratelimit"}},
+	}}, registry)
+	require.NoError(t, err)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/orders/42", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, traceparent, traceID, "the trace continues to the upstream")
+
+	require.NoError(t, flush(context.Background()))
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	assert.Contains(t, stub.services, "apigw-test")
+	server := stub.spans["GET orders"]
+	require.NotNil(t, server, "spans: %v", stub.spans)
+	assert.Equal(t, parentID, hex.EncodeToString(server.ParentSpanId))
+	for _, name := range []string{"auth.authenticate", "ratelimit.allow", "upstream GET"} {
+		span := stub.spans[name]
+		require.NotNil(t, span, name)
+		assert.Equal(t, traceID, hex.EncodeToString(span.TraceId), name)
+		assert.Equal(t, server.SpanId, span.ParentSpanId, "%s is a child of the server span", name)
+	}
+}
+
+func TestInit_WithoutEndpoint(t *testing.T) {
+	flush, err := tracing.Init(context.Background(), config.Tracing{})
+	require.NoError(t, err)
+	assert.NoError(t, flush(context.Background()))
+}
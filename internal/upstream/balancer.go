@@ -0,0 +1,92 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+)
+
+// Supported load balancing strategies
+const (
+	RoundRobin       = "round_robin"
+	Weighted         = "weighted"
+	LeastConnections = "least_connections"
+	ConsistentHash   = "consistent_hash"
+)
+
+// Balancer picks the target of a request among the available ones
+type Balancer interface {
+	Next(r *http.Request, targets []*Target) *Target
+}
+
+// NewBalancer returns the balancer implementing the strategy. An empty
+// strategy selects round robin.
+func NewBalancer(lb config.LoadBalancing) (Balancer, error) {
+	switch lb.Strategy {
+	case "", RoundRobin:
+		return &roundRobin{}, nil
+	case Weighted:
+		return &weighted{}, nil
+	case LeastConnections:
+		return leastConnections{}, nil
+	case ConsistentHash:
+		return newConsistentHash(lb)
+	}
+	return nil, fmt.Errorf("unknown load balancing strategy %q", lb.Strategy)
+}
+
+// roundRobin cycles through the targets in order, ignoring weights
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobin) Next(_ *http.Request, targets []*Target) *Target {
+	if len(targets) == 0 {
+		return nil
+	}
+	n := b.next.Add(1) - 1
+	return targets[n%uint64(len(targets))]
+}
+
+// weighted is nginx's smooth weighted round robin: each pick adds every
+// target's weight to its current value and takes the highest, which then
+// pays back the total. Picks are spread evenly instead of in bursts.
+type weighted struct {
+	mu sync.Mutex
+}
+
+func (b *weighted) Next(_ *http.Request, targets []*Target) *Target {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var best *Target
+	total := 0
+	for _, t := range targets {
+		t.current += t.Weight
+		total += t.Weight
+		if best == nil || t.current > best.current {
+			best = t
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+// leastConnections picks the target with the fewest requests in flight
+// relative to its weight
+type leastConnections struct{}
+
+func (leastConnections) Next(_ *http.Request, targets []*Target) *Target {
+	var best *Target
+	for _, t := range targets {
+		// a/wa < b/wb compared without division
+		if best == nil || t.Active()*int64(best.Weight) < best.Active()*int64(t.Weight) {
+			best = t
+		}
+	}
+	return best
+}
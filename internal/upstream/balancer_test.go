@@ -0,0 +1,220 @@
+package upstream_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+	"github.com/arjunksofficial/tyk-task/internal/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTargets(weights ...int) []*upstream.Target {
+	targets := make([]*upstream.Target, len(weights))
+	for i, w := range weights {
+		targets[i] = upstream.NewTarget(&url.URL{Scheme: "http", Host: fmt.Sprintf("orders-%d:8000", i)}, w)
+	}
+	return targets
+}
+
+// pick returns the index of the targets picked for n requests
+func pick(t *testing.T, b upstream.Balancer, targets []*upstream.Target, n int) []int {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	picked := make([]int, 0, n)
+	for range n {
+		target := b.Next(r, targets)
+		require.NotNil(t, target)
+		for i := range targets {
+			if targets[i] == target {
+				picked = append(picked, i)
+			}
+		}
+	}
+	return picked
+}
+
+func TestNewBalancer(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		lb    config.LoadBalancing
+		isErr bool
+	}{
+		{desc: "Test default strategy", lb: config.LoadBalancing{}},
+		{desc: "Test round robin", lb: config.LoadBalancing{Strategy: upstream.RoundRobin}},
+		{desc: "Test weighted", lb: config.LoadBalancing{Strategy: upstream.Weighted}},
+		{desc: "Test least connections", lb: config.LoadBalancing{Strategy: upstream.LeastConnections}},
+		{desc: "Test consistent hash on api key", lb: config.LoadBalancing{Strategy: upstream.ConsistentHash}},
+		{
+			desc: "Test consistent hash on header",
+			lb:   config.LoadBalancing{Strategy: upstream.ConsistentHash, HashOn: upstream.HashOnHeader, Header: "X-User-ID"},
+		},
+		{
+			desc:  "Test consistent hash on header without header",
+			lb:    config.LoadBalancing{Strategy: upstream.ConsistentHash, HashOn: upstream.HashOnHeader},
+			isErr: true,
+		},
+		{
+			desc:  "Test unknown hash key",
+			lb:    config.LoadBalancing{Strategy: upstream.ConsistentHash, HashOn: "cookie"},
+			isErr: true,
+		},
+		{desc: "Test unknown strategy", lb: config.LoadBalancing{Strategy: "random"}, isErr: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			b, err := upstream.NewBalancer(tC.lb)
+			if tC.isErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, b)
+		})
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	b, err := upstream.NewBalancer(config.LoadBalancing{Strategy: upstream.RoundRobin})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 0, 1, 2}, pick(t, b, newTargets(5, 1, 1), 6))
+	assert.Nil(t, b.Next(httptest.NewRequest(http.MethodGet, "/", nil), nil))
+}
+
+func TestWeighted(t *testing.T) {
+	b, err := upstream.NewBalancer(config.LoadBalancing{Strategy: upstream.Weighted})
+	require.NoError(t, err)
+	// Smooth weighted round robin interleaves the heavy target
+	assert.Equal(t, []int{0, 1, 0, 2, 0, 0, 1, 0, 2, 0}, pick(t, b, newTargets(3, 1, 1), 10))
+}
+
+func TestLeastConnections(t *testing.T) {
+	b, err := upstream.NewBalancer(config.LoadBalancing{Strategy: upstream.LeastConnections})
+	require.NoError(t, err)
+	targets := newTargets(1, 1, 2)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	done := targets[0].Acquire()
+	assert.Equal(t, targets[1], b.Next(r, targets))
+	targets[1].Acquire()
+	// Two requests in flight on a target of weight 2 count as one
+	targets[2].Acquire()
+	targets[2].Acquire()
+	assert.Equal(t, targets[0], b.Next(r, targets))
+	done()
+	assert.Equal(t, targets[0], b.Next(r, targets))
+	assert.Equal(t, int64(0), targets[0].Active())
+	assert.Equal(t, int64(2), targets[2].Active())
+}
+
+func TestConsistentHash(t *testing.T) {
+	targets := newTargets(1, 1, 1, 1)
+	byKey := func(b upstream.Balancer, targets []*upstream.Target, keys int, request func(key string) *http.Request) map[string]*upstream.Target {
+		picked := map[string]*upstream.Target{}
+		for i := range keys {
+			key := fmt.Sprintf("key-%d", i)
+			picked[key] = b.Next(request(key), targets)
+		}
+		return picked
+	}
+
+	t.Run("Test same api key goes to the same target", func(t *testing.T) {
+		b, err := upstream.NewBalancer(config.LoadBalancing{Strategy: upstream.ConsistentHash})
+		require.NoError(t, err)
+		request := func(key string) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			token := models.TokenData{KeyHash: key}
+			return r.WithContext(context.WithValue(r.Context(), models.TokenContextKey, token))
+		}
+		first := byKey(b, targets, 200, request)
+		assert.Equal(t, first, byKey(b, targets, 200, request))
+
+		counts := map[*upstream.Target]int{}
+		for _, target := range first {
+			counts[target]++
+		}
+		assert.Len(t, counts, len(targets), "keys should be spread over every target")
+	})
+
+	t.Run("Test removing a target only moves its keys", func(t *testing.T) {
+		b, err := upstream.NewBalancer(config.LoadBalancing{
+			Strategy: upstream.ConsistentHash,
+			HashOn:   upstream.HashOnHeader,
+			Header:   "X-User-ID",
+		})
+		require.NoError(t, err)
+		request := func(key string) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-User-ID", key)
+			return r
+		}
+		before := byKey(b, targets, 500, request)
+		after := byKey(b, targets[1:], 500, request)
+		for key, target := range before {
+			if target != targets[0] {
+				assert.Equal(t, target, after[key], key)
+			}
+		}
+	})
+
+	t.Run("Test weights set each target's share of keys", func(t *testing.T) {
+		b, err := upstream.NewBalancer(config.LoadBalancing{Strategy: upstream.ConsistentHash})
+		require.NoError(t, err)
+		weighted := newTargets(3, 1)
+		request := func(key string) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+key)
+			return r
+		}
+		counts := map[*upstream.Target]int{}
+		for _, target := range byKey(b, weighted, 4000, request) {
+			counts[target]++
+		}
+		assert.InDelta(t, 3000, counts[weighted[0]], 200)
+	})
+}
+
+func TestNewPool(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		route    config.Route
+		expected []string
+		weights  []int
+	}{
+		{
+			desc:     "Test host is the only target",
+			route:    config.Route{Path: "/api/v1/orders/", Host: "http://orders:8000"},
+			expected: []string{"http://orders:8000"},
+			weights:  []int{1},
+		},
+		{
+			desc: "Test targets with default weight",
+			route: config.Route{Path: "/api/v1/orders/", Targets: []config.Target{
+				{URL: "http://orders-1:8000", Weight: 2},
+				{URL: "http://orders-2:8000"},
+			}},
+			expected: []string{"http://orders-1:8000", "http://orders-2:8000"},
+			weights:  []int{2, 1},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			pool, err := upstream.NewPool(tC.route)
+			require.NoError(t, err)
+			var urls []string
+			var weights []int
+			for _, target := range pool.Targets() {
+				urls = append(urls, target.URL.String())
+				weights = append(weights, target.Weight)
+			}
+			assert.Equal(t, tC.expected, urls)
+			assert.Equal(t, tC.weights, weights)
+		})
+	}
+}
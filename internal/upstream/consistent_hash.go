@@ -0,0 +1,84 @@
+package upstream
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/middlewares/logging"
+	"github.com/arjunksofficial/tyk-task/internal/token/models"
+)
+
+// Request attributes consistent hashing keys on
+const (
+	HashOnAPIKey = "api_key"
+	HashOnHeader = "header"
+)
+
+// consistentHash sends requests with the same key to the same target using
+// weighted rendezvous hashing: every target scores the key and the highest
+// score wins. When a target leaves the pool only its keys move.
+type consistentHash struct {
+	key func(r *http.Request) string
+}
+
+func newConsistentHash(lb config.LoadBalancing) (*consistentHash, error) {
+	switch lb.HashOn {
+	case "", HashOnAPIKey:
+		return &consistentHash{key: apiKey}, nil
+	case HashOnHeader:
+		if lb.Header == "" {
+			return nil, fmt.Errorf("hashing on header requires load_balancing.header")
+		}
+		return &consistentHash{key: func(r *http.Request) string {
+			return r.Header.Get(lb.Header)
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown consistent hash key %q", lb.HashOn)
+}
+
+func (b *consistentHash) Next(r *http.Request, targets []*Target) *Target {
+	key := b.key(r)
+	if key == "" {
+		key = logging.ClientIP(r)
+	}
+	var best *Target
+	bestScore := math.Inf(-1)
+	for _, t := range targets {
+		h := fnv.New64a()
+		h.Write([]byte(t.URL.String()))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		// Map the hash into (0, 1) and weight it so a target's share of
+		// keys is proportional to its weight
+		u := (float64(mix(h.Sum64())>>11) + 0.5) / (1 << 53)
+		score := float64(t.Weight) / -math.Log(u)
+		if score > bestScore {
+			best, bestScore = t, score
+		}
+	}
+	return best
+}
+
+// mix is the splitmix64 finalizer. FNV alone barely changes its high bits
+// for keys differing in their last bytes.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// apiKey identifies the client by the token authenticated earlier in the
+// chain, falling back to the raw Authorization header
+func apiKey(r *http.Request) string {
+	if token, ok := r.Context().Value(models.TokenContextKey).(models.TokenData); ok {
+		return token.RateLimitKey()
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,161 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+)
+
+// Probe settings used by withDefaults for a route's health_check
+const (
+	DefaultInterval           = 10 * time.Second
+	DefaultTimeout            = 2 * time.Second
+	DefaultHealthyThreshold   = 2
+	DefaultUnhealthyThreshold = 3
+	DefaultEjectionDuration   = 30 * time.Second
+)
+
+func withDefaults(hc config.HealthCheck) config.HealthCheck {
+	if hc.Active.Interval == 0 {
+		hc.Active.Interval = DefaultInterval
+	}
+	if hc.Active.Timeout == 0 {
+		hc.Active.Timeout = DefaultTimeout
+	}
+	if hc.Active.HealthyThreshold == 0 {
+		hc.Active.HealthyThreshold = DefaultHealthyThreshold
+	}
+	if hc.Active.UnhealthyThreshold == 0 {
+		hc.Active.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if hc.Passive.EjectionDuration == 0 {
+		hc.Passive.EjectionDuration = DefaultEjectionDuration
+	}
+	return hc
+}
+
+// Start publishes the health of the pool's targets and starts probing them
+// when active health checks are configured
+func (p *Pool) Start() {
+	for _, t := range p.targets {
+		p.publish(t)
+	}
+	if p.health.Active.Path == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stop = cancel
+	for _, t := range p.targets {
+		p.wg.Add(1)
+		go p.check(ctx, t)
+	}
+}
+
+// Close stops the health checks, waits for probes in flight and closes
+// their idle connections
+func (p *Pool) Close() {
+	p.closed.Store(true)
+	if p.stop != nil {
+		p.stop()
+	}
+	p.wg.Wait()
+	p.client.CloseIdleConnections()
+}
+
+// Report records the outcome of a request proxied to the target. Targets
+// failing PassiveHealthCheck.UnhealthyThreshold requests in a row are
+// ejected for the EjectionDuration.
+func (p *Pool) Report(t *Target, ok bool) {
+	threshold := int64(p.health.Passive.UnhealthyThreshold)
+	if threshold == 0 {
+		return
+	}
+	if ok {
+		t.failures.Store(0)
+		return
+	}
+	if t.failures.Add(1) < threshold {
+		return
+	}
+	t.failures.Store(0)
+	ejection := p.health.Passive.EjectionDuration
+	t.ejectedUntil.Store(time.Now().Add(ejection).UnixNano())
+	log.Printf("Upstream %s of route %s ejected for %s after %d failed requests", t.URL, p.name, ejection, threshold)
+	p.publish(t)
+	time.AfterFunc(ejection, func() { p.publish(t) })
+}
+
+// check probes the target every interval until ctx is done
+func (p *Pool) check(ctx context.Context, t *Target) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.health.Active.Interval)
+	defer ticker.Stop()
+	for {
+		p.probe(ctx, t)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) probe(ctx context.Context, t *Target) {
+	err := p.get(ctx, t)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil {
+		t.failed = 0
+		t.successes++
+		if !t.healthy.Load() && t.successes >= p.health.Active.HealthyThreshold {
+			t.healthy.Store(true)
+			log.Printf("Upstream %s of route %s is healthy", t.URL, p.name)
+			p.publish(t)
+		}
+		return
+	}
+	t.successes = 0
+	t.failed++
+	if t.healthy.Load() && t.failed >= p.health.Active.UnhealthyThreshold {
+		t.healthy.Store(false)
+		log.Printf("Upstream %s of route %s is unhealthy: %v", t.URL, p.name, err)
+		p.publish(t)
+	}
+}
+
+// get requests the health check path of the target
+func (p *Pool) get(ctx context.Context, t *Target) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL.JoinPath(p.health.Active.Path).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// publish exports whether the target is in rotation. Pools replaced by a
+// reload stay silent so they cannot overwrite the new pool's state.
+func (p *Pool) publish(t *Target) {
+	if p.closed.Load() {
+		return
+	}
+	healthy := 0.0
+	if t.Available(time.Now()) {
+		healthy = 1
+	}
+	metrics.UpstreamTargetHealthy.WithLabelValues(p.name, t.URL.String()).Set(healthy)
+}
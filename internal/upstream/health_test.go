@@ -0,0 +1,115 @@
+package upstream_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/metrics"
+	"github.com/arjunksofficial/tyk-task/internal/upstream"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func healthy(route string, target *upstream.Target) float64 {
+	return testutil.ToFloat64(metrics.UpstreamTargetHealthy.WithLabelValues(route, target.URL.String()))
+}
+
+func TestPool_ActiveHealthCheck(t *testing.T) {
+	var down atomic.Bool
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer flaky.Close()
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer stable.Close()
+
+	pool, err := upstream.NewPool(config.Route{
+		Name:    "active",
+		Targets: []config.Target{{URL: flaky.URL}, {URL: stable.URL}},
+		HealthCheck: config.HealthCheck{Active: config.ActiveHealthCheck{
+			Path:               "/healthz",
+			Interval:           10 * time.Millisecond,
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 2,
+		}},
+	})
+	require.NoError(t, err)
+	pool.Start()
+	defer pool.Close()
+	flakyTarget, stableTarget := pool.Targets()[0], pool.Targets()[1]
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.Equal(t, 1.0, healthy("active", flakyTarget))
+
+	down.Store(true)
+	assert.Eventually(t, func() bool { return !flakyTarget.Available(time.Now()) }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0.0, healthy("active", flakyTarget))
+	assert.Equal(t, 1.0, healthy("active", stableTarget))
+	for range 4 {
+		assert.Equal(t, stableTarget, pool.Next(r))
+	}
+
+	down.Store(false)
+	assert.Eventually(t, func() bool { return flakyTarget.Available(time.Now()) }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1.0, healthy("active", flakyTarget))
+}
+
+func TestPool_PassiveHealthCheck(t *testing.T) {
+	pool, err := upstream.NewPool(config.Route{
+		Name:    "passive",
+		Targets: []config.Target{{URL: "http://orders-1:8000"}, {URL: "http://orders-2:8000"}},
+		HealthCheck: config.HealthCheck{Passive: config.PassiveHealthCheck{
+			UnhealthyThreshold: 3,
+			EjectionDuration:   50 * time.Millisecond,
+		}},
+	})
+	require.NoError(t, err)
+	pool.Start()
+	defer pool.Close()
+	first, second := pool.Targets()[0], pool.Targets()[1]
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// A success in between resets the count
+	pool.Report(first, false)
+	pool.Report(first, false)
+	pool.Report(first, true)
+	pool.Report(first, false)
+	assert.True(t, first.Available(time.Now()))
+
+	pool.Report(first, false)
+	pool.Report(first, false)
+	assert.False(t, first.Available(time.Now()))
+	assert.Equal(t, 0.0, healthy("passive", first))
+	for range 4 {
+		assert.Equal(t, second, pool.Next(r))
+	}
+
+	pool.Report(second, false)
+	pool.Report(second, false)
+	pool.Report(second, false)
+	assert.Nil(t, pool.Next(r), "every target is ejected")
+
+	assert.Eventually(t, func() bool { return healthy("passive", first) == 1 }, time.Second, 5*time.Millisecond)
+	assert.NotNil(t, pool.Next(r))
+}
+
+func TestPool_PassiveHealthCheckDisabled(t *testing.T) {
+	pool, err := upstream.NewPool(config.Route{Name: "disabled", Host: "http://orders:8000"})
+	require.NoError(t, err)
+	target := pool.Targets()[0]
+	for range 10 {
+		pool.Report(target, false)
+	}
+	assert.True(t, target.Available(time.Now()))
+}
@@ -0,0 +1,61 @@
+package upstream
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/tlsconfig"
+)
+
+// Dial and connection pool settings used when a route has no timeouts
+// configured
+const (
+	DefaultConnectTimeout        = 5 * time.Second
+	DefaultResponseHeaderTimeout = 30 * time.Second
+	DefaultMaxIdleConnsPerHost   = 32
+	DefaultIdleConnTimeout       = 90 * time.Second
+)
+
+// NewTransport creates the transport proxying to the route's targets
+func NewTransport(route config.Route) (*http.Transport, error) {
+	tlsCfg, err := tlsconfig.NewUpstream(route.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("route %s: tls: %w", route.GetName(), err)
+	}
+
+	connect := route.Timeouts.Connect
+	if connect == 0 {
+		connect = DefaultConnectTimeout
+	}
+	responseHeader := route.Timeouts.ResponseHeader
+	if responseHeader == 0 {
+		responseHeader = DefaultResponseHeaderTimeout
+	}
+	maxIdle := route.Transport.MaxIdleConnsPerHost
+	if maxIdle == 0 {
+		maxIdle = DefaultMaxIdleConnsPerHost
+	}
+	idle := route.Transport.IdleConnTimeout
+	if idle == 0 {
+		idle = DefaultIdleConnTimeout
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connect,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig:       tlsCfg,
+		TLSHandshakeTimeout:   connect,
+		ResponseHeaderTimeout: responseHeader,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          maxIdle * len(route.GetTargets()),
+		MaxIdleConnsPerHost:   maxIdle,
+		IdleConnTimeout:       idle,
+	}, nil
+}
@@ -0,0 +1,142 @@
+package upstream_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+	"github.com/arjunksofficial/tyk-task/internal/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePEM writes the blocks to a file in dir
+func writePEM(t *testing.T, dir, name string, blocks ...*pem.Block) string {
+	t.Helper()
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, pem.EncodeToMemory(block)...)
+	}
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// writeClientCert writes a self-signed client certificate and its key,
+// returning their paths and the certificate
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "apigw"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, dir, "client.crt", &pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		writePEM(t, dir, "client.key", &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		cert
+}
+
+func TestNewTransport_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.VerifyClientCertIfGiven}
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := writePEM(t, dir, "ca.crt", &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	testCases := []struct {
+		desc     string
+		tls      config.UpstreamTLS
+		isErr    bool
+		expected string
+	}{
+		{desc: "Test unknown CA is refused", isErr: true},
+		{desc: "Test CA bundle", tls: config.UpstreamTLS{CAFile: caFile}},
+		{desc: "Test insecure skip verify", tls: config.UpstreamTLS{InsecureSkipVerify: true}},
+		{
+			// The httptest certificate is issued for example.com
+			desc: "Test server name override",
+			tls:  config.UpstreamTLS{CAFile: caFile, ServerName: "example.com"},
+		},
+		{
+			desc:  "Test server name mismatch",
+			tls:   config.UpstreamTLS{CAFile: caFile, ServerName: "orders.internal"},
+			isErr: true,
+		},
+		{
+			desc:     "Test client certificate",
+			tls:      config.UpstreamTLS{CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+			expected: "apigw",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			transport, err := upstream.NewTransport(config.Route{Name: "orders", Host: srv.URL, TLS: tC.tls})
+			require.NoError(t, err)
+			defer transport.CloseIdleConnections()
+
+			resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+			if tC.isErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tC.expected, resp.Header.Get("X-Client"))
+		})
+	}
+}
+
+func TestNewTransport_TLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	testCases := []struct {
+		desc string
+		tls  config.UpstreamTLS
+	}{
+		{desc: "Test missing CA bundle", tls: config.UpstreamTLS{CAFile: filepath.Join(dir, "missing.crt")}},
+		{desc: "Test CA bundle without certificates", tls: config.UpstreamTLS{CAFile: notPEM}},
+		{desc: "Test invalid client certificate", tls: config.UpstreamTLS{CertFile: notPEM, KeyFile: notPEM}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := upstream.NewTransport(config.Route{Name: "orders", Host: "https://orders:8443", TLS: tC.tls})
+			assert.ErrorContains(t, err, "route orders: tls:")
+		})
+	}
+}
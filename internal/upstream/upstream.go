@@ -0,0 +1,131 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arjunksofficial/tyk-task/internal/config"
+)
+
+// Target is one upstream server of a route
+type Target struct {
+	URL    *url.URL
+	Weight int
+
+	active atomic.Int64
+	// current is the smooth weighted round robin state, guarded by the
+	// weighted balancer
+	current int
+
+	// healthy is the verdict of the active health check
+	healthy atomic.Bool
+	// ejectedUntil is the unix nano time a passive ejection ends
+	ejectedUntil atomic.Int64
+	// failures counts consecutive failed proxied requests
+	failures atomic.Int64
+	// successes and failed count consecutive probe results, only touched
+	// by the target's checker goroutine
+	successes, failed int
+}
+
+// NewTarget creates a target in rotation
+func NewTarget(u *url.URL, weight int) *Target {
+	t := &Target{URL: u, Weight: weight}
+	t.healthy.Store(true)
+	return t
+}
+
+// Available reports whether the target is in rotation at now
+func (t *Target) Available(now time.Time) bool {
+	return t.healthy.Load() && now.UnixNano() >= t.ejectedUntil.Load()
+}
+
+// Active returns the number of requests in flight to the target
+func (t *Target) Active() int64 {
+	return t.active.Load()
+}
+
+// Acquire counts a request in flight to the target until the returned func
+// is called
+func (t *Target) Acquire() func() {
+	t.active.Add(1)
+	return func() { t.active.Add(-1) }
+}
+
+// Pool spreads the requests of a route over its targets, skipping the ones
+// its health checks removed from rotation
+type Pool struct {
+	name     string
+	targets  []*Target
+	balancer Balancer
+	health   config.HealthCheck
+	client   *http.Client
+
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+	closed atomic.Bool
+}
+
+// NewPool creates the pool of the route's targets
+func NewPool(route config.Route) (*Pool, error) {
+	balancer, err := NewBalancer(route.LoadBalancing)
+	if err != nil {
+		return nil, fmt.Errorf("route %s: %w", route.GetName(), err)
+	}
+	pool := &Pool{
+		name:     route.GetName(),
+		balancer: balancer,
+		health:   withDefaults(route.HealthCheck),
+	}
+	// Probes use their own transport, so they connect like proxied
+	// requests but do not compete with them for idle connections
+	transport, err := NewTransport(route)
+	if err != nil {
+		return nil, err
+	}
+	pool.client = &http.Client{Transport: transport, Timeout: pool.health.Active.Timeout}
+	for _, target := range route.GetTargets() {
+		u, err := url.Parse(target.URL)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: parsing target %s: %w", route.GetName(), target.URL, err)
+		}
+		pool.targets = append(pool.targets, NewTarget(u, target.Weight))
+	}
+	return pool, nil
+}
+
+// Name returns the name of the route the pool serves
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Targets returns every target of the pool
+func (p *Pool) Targets() []*Target {
+	return p.targets
+}
+
+// Next picks the target serving the request among the available ones. It
+// returns nil when every target is out of rotation.
+func (p *Pool) Next(r *http.Request) *Target {
+	now := time.Now()
+	available := p.targets
+	for i, t := range p.targets {
+		if t.Available(now) {
+			continue
+		}
+		// Copy only once a target has to be skipped
+		available = append(make([]*Target, 0, len(p.targets)), p.targets[:i]...)
+		for _, t := range p.targets[i+1:] {
+			if t.Available(now) {
+				available = append(available, t)
+			}
+		}
+		break
+	}
+	return p.balancer.Next(r, available)
+}